@@ -1,35 +1,271 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	// Timeseries time zones must resolve on hosts without a zoneinfo database.
+	_ "time/tzdata"
 
 	"link-shortener/internal/api"
+	"link-shortener/internal/auth"
+	"link-shortener/internal/clicks"
+	"link-shortener/internal/geo"
+	"link-shortener/internal/model"
+	"link-shortener/internal/privacy"
+	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/postgres"
 	"link-shortener/internal/storage/sqlite"
 )
 
 const defaultAddr = ":8080"
 const defaultBaseURL = "http://localhost:8080"
 const defaultDBPath = "data.db"
+const defaultRedirectCacheSize = 10000
+const defaultRedirectCacheTTL = 5 * time.Minute
+const shutdownTimeout = 10 * time.Second
 
 func main() {
-	store, err := sqlite.New(dbPath())
+	createAdminKey := flag.String("create-admin-key", "", "create an admin user with the given name (if missing) and an admin API key for it, print the key and exit")
+	assignUnowned := flag.Int64("assign-unowned-links", 0, "give every link without an owner to the user with this ID and exit")
+	migrateCmd := flag.String("migrate", "", "`command`: status lists schema migrations, up applies pending ones; then exit")
+	anonymizeClicks := flag.Bool("anonymize-clicks", false, "rewrite stored clicks the way privacy mode records them (truncated IPs, hashed visitors) and exit")
+	flag.Parse()
+
+	if *migrateCmd != "" {
+		runMigrations(*migrateCmd)
+		return
+	}
+
+	db := databaseConfig()
+	store, err := openStore(db)
+	if err != nil {
+		log.Fatalf("failed to initialize %s store: %v", db.driver, err)
+	}
+
+	if *createAdminKey != "" {
+		bootstrapAdminKey(store, *createAdminKey)
+		return
+	}
+	if *assignUnowned != 0 {
+		n, err := store.AssignUnownedLinks(context.Background(), *assignUnowned)
+		if err != nil {
+			log.Fatalf("failed to assign links: %v", err)
+		}
+		fmt.Printf("assigned %d links to user %d\n", n, *assignUnowned)
+		return
+	}
+	if *anonymizeClicks {
+		n, err := store.AnonymizeClicks(context.Background(), visitorAnonymizer().Anonymize)
+		if err != nil {
+			log.Fatalf("failed to anonymize clicks: %v", err)
+		}
+		fmt.Printf("anonymized %d clicks\n", n)
+		return
+	}
+
+	var apiKeys storage.APIKeyStore = store
+	if !envBool("API_AUTH", true) {
+		log.Printf("API_AUTH disabled: /api endpoints are open to everyone")
+		apiKeys = nil
+	}
+	locator, err := geoLocator()
+	if err != nil {
+		log.Fatalf("failed to initialize geo lookup: %v", err)
+	}
+	if closer, ok := locator.(io.Closer); ok {
+		defer closer.Close()
+	}
+	geoCache := geo.CacheConfig{
+		Size: envInt("GEOIP_CACHE_SIZE", 0),
+		TTL:  envDuration("GEOIP_CACHE_TTL", 0),
+	}
+	if envBool("GEOIP_CACHE_PERSIST", false) {
+		geoCache.Persister = store
+	}
+
+	trustedProxies, err := api.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	botNetworks, err := api.ParseBotNetworks(os.Getenv("BOT_IP_RANGES"))
 	if err != nil {
-		log.Fatalf("failed to initialize sqlite store: %v", err)
+		log.Fatalf("invalid BOT_IP_RANGES: %v", err)
 	}
+	var anonymizer *privacy.Anonymizer
+	if envBool("PRIVACY_MODE", false) {
+		anonymizer = visitorAnonymizer()
+	}
+
 	server := api.NewServer(api.Config{
-		Store:   store,
-		BaseURL: baseURL(),
+		Store:              store,
+		APIKeys:            apiKeys,
+		Users:              store,
+		Workspaces:         store,
+		BaseURL:            baseURL(),
+		Geo:                geo.NewCache(locator, geoCache),
+		TrustedProxies:     trustedProxies,
+		TrustXRealIP:       envBool("TRUST_X_REAL_IP", false),
+		BotNetworks:        botNetworks,
+		Anonymizer:         anonymizer,
+		RateLimits:         rateLimits(),
+		RedirectCacheSize:  envInt("REDIRECT_CACHE_SIZE", defaultRedirectCacheSize),
+		RedirectCacheTTL:   envDuration("REDIRECT_CACHE_TTL", defaultRedirectCacheTTL),
+		ClickBufferSize:    envInt("CLICK_BUFFER_SIZE", 0),
+		ClickWorkers:       envInt("CLICK_WORKERS", 0),
+		ClickBatchSize:     envInt("CLICK_BATCH_SIZE", 0),
+		ClickFlushInterval: envDuration("CLICK_FLUSH_INTERVAL", 0),
+		ClickQueuePolicy:   clickQueuePolicy(),
 	})
 
 	addr := listenAddr()
-	log.Printf("server listening on %s", addr)
+	httpServer := &http.Server{Addr: addr, Handler: server.Routes()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("server listening on %s", addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server stopped: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down")
 
-	if err := http.ListenAndServe(addr, server.Routes()); err != nil {
-		log.Fatalf("server stopped: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
 	}
+	if err := server.Close(shutdownCtx); err != nil {
+		log.Printf("flushing clicks: %v", err)
+	}
+}
+
+// backend is what the server needs from a database.
+type backend interface {
+	storage.Store
+	storage.APIKeyStore
+	storage.UserStore
+	storage.WorkspaceStore
+	storage.ClickAnonymizer
+	geo.Persister
+}
+
+type database struct {
+	driver string
+	dsn    string
+}
+
+// databaseConfig picks the backend from the scheme of DATABASE_URL:
+// postgres:// or postgresql:// for PostgreSQL, sqlite:path for SQLite. Without
+// DATABASE_URL it falls back to the SQLite file at SQLITE_PATH.
+func databaseConfig() database {
+	val := strings.TrimSpace(os.Getenv("DATABASE_URL"))
+	if val == "" {
+		return database{driver: "sqlite", dsn: dbPath()}
+	}
+	scheme, rest, ok := strings.Cut(val, ":")
+	if !ok {
+		log.Fatalf("invalid DATABASE_URL: missing scheme")
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return database{driver: "postgres", dsn: val}
+	case "sqlite", "sqlite3":
+		return database{driver: "sqlite", dsn: strings.TrimPrefix(rest, "//")}
+	default:
+		log.Fatalf("invalid DATABASE_URL: unsupported scheme %q", scheme)
+		return database{}
+	}
+}
+
+func openStore(db database) (backend, error) {
+	if db.driver == "postgres" {
+		return postgres.New(db.dsn)
+	}
+	return sqlite.New(db.dsn)
+}
+
+func bootstrapAdminKey(store backend, name string) {
+	ctx := context.Background()
+	user, err := store.UserByName(ctx, name)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		created, err := api.CreateUser(ctx, store, name, model.RoleAdmin, 0)
+		if err != nil {
+			log.Fatalf("failed to create user: %v", err)
+		}
+		user = created
+	} else if err != nil {
+		log.Fatalf("failed to look up user: %v", err)
+	}
+	if !user.IsAdmin() {
+		log.Fatalf("user %q exists and is not an admin", name)
+	}
+	token, key, err := api.IssueAPIKey(ctx, store, user.ID, name, []string{auth.ScopeAdmin})
+	if err != nil {
+		log.Fatalf("failed to create API key: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "created API key %d for user %d (%s); store it now, it cannot be shown again:\n", key.ID, user.ID, user.Name)
+	fmt.Println(token)
+}
+
+func runMigrations(cmd string) {
+	db := databaseConfig()
+	status, migrate := sqlite.MigrationStatus, sqlite.Migrate
+	if db.driver == "postgres" {
+		status, migrate = postgres.MigrationStatus, postgres.Migrate
+	}
+	switch cmd {
+	case "status":
+		migrations, err := status(db.dsn)
+		if err != nil {
+			log.Fatalf("failed to read migrations: %v", err)
+		}
+		for _, m := range migrations {
+			state := "pending"
+			if !m.Pending() {
+				state = "applied " + m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-24s %s\n", m.Version, m.Name, state)
+		}
+	case "up":
+		applied, err := migrate(db.dsn)
+		for _, m := range applied {
+			fmt.Printf("applied %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("failed to migrate: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	default:
+		log.Fatalf("unknown -migrate command %q (use status or up)", cmd)
+	}
+}
+
+// visitorAnonymizer keys visitor hashes with VISITOR_HASH_SECRET. There is no
+// fallback: hashes made with a throwaway secret never match later ones, and
+// the raw IPs they replaced are gone.
+func visitorAnonymizer() *privacy.Anonymizer {
+	secret := os.Getenv("VISITOR_HASH_SECRET")
+	if secret == "" {
+		log.Fatalf("VISITOR_HASH_SECRET is required for privacy mode and -anonymize-clicks")
+	}
+	return privacy.New([]byte(secret))
 }
 
 func listenAddr() string {
@@ -52,3 +288,94 @@ func dbPath() string {
 	}
 	return defaultDBPath
 }
+
+func geoLocator() (geo.Locator, error) {
+	if val := strings.TrimSpace(os.Getenv("GEOIP_DB_PATH")); val != "" {
+		var paths []string
+		for _, path := range strings.Split(val, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return geo.NewMMDBLocator(envDuration("GEOIP_DB_RELOAD_INTERVAL", 0), paths...)
+	}
+	endpoint := geo.DefaultEndpoint
+	if val := strings.TrimSpace(os.Getenv("GEOIP_ENDPOINT")); val != "" {
+		endpoint = val
+	}
+	return geo.NewHTTPLocator(endpoint), nil
+}
+
+func rateLimits() api.RateLimits {
+	limits := api.DefaultRateLimits()
+	policies := []struct {
+		name   string
+		policy *api.RateLimitPolicy
+	}{
+		{"RATE_LIMIT_SHORTEN", &limits.Shorten},
+		{"RATE_LIMIT_ANALYTICS", &limits.Analytics},
+		{"RATE_LIMIT_REDIRECT", &limits.Redirect},
+	}
+	for _, p := range policies {
+		if val := strings.TrimSpace(os.Getenv(p.name)); val != "" {
+			policy, err := api.ParseRateLimitPolicy(val)
+			if err != nil {
+				log.Fatalf("invalid %s: %v", p.name, err)
+			}
+			*p.policy = policy
+		}
+		p.policy.Burst = envInt(p.name+"_BURST", p.policy.Burst)
+	}
+	return limits
+}
+
+func clickQueuePolicy() clicks.Policy {
+	switch val := clicks.Policy(strings.TrimSpace(os.Getenv("CLICK_QUEUE_POLICY"))); val {
+	case "":
+		return clicks.PolicyDrop
+	case clicks.PolicyDrop, clicks.PolicyBlock:
+		return val
+	default:
+		log.Printf("ignoring invalid CLICK_QUEUE_POLICY=%q, using %s", val, clicks.PolicyDrop)
+		return clicks.PolicyDrop
+	}
+}
+
+func envInt(name string, fallback int) int {
+	val := strings.TrimSpace(os.Getenv(name))
+	if val == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		log.Printf("ignoring invalid %s=%q, using %d", name, val, fallback)
+		return fallback
+	}
+	return n
+}
+
+func envBool(name string, fallback bool) bool {
+	val := strings.TrimSpace(os.Getenv(name))
+	if val == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q, using %t", name, val, fallback)
+		return fallback
+	}
+	return b
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	val := strings.TrimSpace(os.Getenv(name))
+	if val == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		log.Printf("ignoring invalid %s=%q, using %s", name, val, fallback)
+		return fallback
+	}
+	return d
+}
@@ -1,6 +1,7 @@
 import { useState } from 'react'
 import './App.css'
 import Analytics from './components/Analytics'
+import { apiFetch, getApiKey, setApiKey } from './api'
 
 const toRFC3339LocalMidnight = (dateValue) => {
   if (!dateValue) return null
@@ -47,6 +48,20 @@ function App() {
   const [loading, setLoading] = useState(false)
   const [copiedResult, setCopiedResult] = useState(false)
   const [analyticsRefresh, setAnalyticsRefresh] = useState(0)
+  const [apiKey, setApiKeyState] = useState(getApiKey)
+  const [apiKeyInput, setApiKeyInput] = useState('')
+
+  const updateApiKey = (key) => {
+    setApiKey(key)
+    setApiKeyState(key)
+    setApiKeyInput('')
+    setAnalyticsRefresh((value) => value + 1)
+  }
+
+  const handleApiKeySubmit = (event) => {
+    event.preventDefault()
+    updateApiKey(apiKeyInput.trim())
+  }
 
   const handleSubmit = async (event) => {
     event.preventDefault()
@@ -56,7 +71,7 @@ function App() {
 
     try {
       const payload = buildPayload(url, customAlias, expiresAt)
-      const response = await fetch('/api/shorten', {
+      const response = await apiFetch('/api/shorten', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(payload),
@@ -91,6 +106,33 @@ function App() {
       </header>
 
       <main className="panel">
+        <form className="api-key" onSubmit={handleApiKeySubmit}>
+          <div className="field">
+            <label htmlFor="apiKey">API key</label>
+            <input
+              id="apiKey"
+              name="apiKey"
+              type="password"
+              autoComplete="off"
+              placeholder={apiKey ? 'Key saved for this tab' : 'lsk_...'}
+              value={apiKeyInput}
+              onChange={(event) => setApiKeyInput(event.target.value)}
+            />
+            <p className="helper">
+              Needs links:write to shorten and analytics:read for analytics.
+              Kept in this tab's session storage only.
+            </p>
+          </div>
+          <button className="primary" type="submit" disabled={!apiKeyInput.trim()}>
+            Use key
+          </button>
+          {apiKey ? (
+            <button className="copy" type="button" onClick={() => updateApiKey('')}>
+              Forget key
+            </button>
+          ) : null}
+        </form>
+
         <form className="form" onSubmit={handleSubmit}>
           <div className="field">
             <label htmlFor="url">Destination URL</label>
@@ -0,0 +1,22 @@
+// The API key is typed into the UI and kept in session storage, so it is
+// never compiled into the bundle and is forgotten when the tab closes.
+const storageKey = 'linkshortener.apiKey'
+
+export const getApiKey = () => sessionStorage.getItem(storageKey) || ''
+
+export const setApiKey = (key) => {
+  if (key) {
+    sessionStorage.setItem(storageKey, key)
+  } else {
+    sessionStorage.removeItem(storageKey)
+  }
+}
+
+export const apiFetch = (path, options = {}) => {
+  const headers = { ...(options.headers || {}) }
+  const apiKey = getApiKey()
+  if (apiKey) {
+    headers.Authorization = `Bearer ${apiKey}`
+  }
+  return fetch(path, { ...options, headers })
+}
@@ -1,10 +1,12 @@
 import { useEffect, useState } from 'react'
 import './Analytics.css'
+import { apiFetch } from '../api'
 
 const formatCount = (value) => (Number.isFinite(value) ? value : 0)
 
 function Analytics({ formatExpiry, refreshKey }) {
   const [links, setLinks] = useState([])
+  const [nextCursor, setNextCursor] = useState('')
   const [linksError, setLinksError] = useState('')
   const [linksLoading, setLinksLoading] = useState(false)
   const [lookupCode, setLookupCode] = useState('')
@@ -15,17 +17,20 @@ function Analytics({ formatExpiry, refreshKey }) {
   const [copiedShort, setCopiedShort] = useState(false)
   const [copiedOriginal, setCopiedOriginal] = useState(false)
 
-  const fetchLinks = async () => {
+  const fetchLinks = async (cursor = '') => {
     setLinksLoading(true)
     setLinksError('')
     try {
-      const response = await fetch('/api/links')
+      const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : ''
+      const response = await apiFetch(`/api/links${query}`)
       if (!response.ok) {
         const message = await response.text()
         throw new Error(message || 'Failed to load links')
       }
       const data = await response.json()
-      setLinks(Array.isArray(data) ? data : [])
+      const items = Array.isArray(data.items) ? data.items : []
+      setLinks((current) => (cursor ? [...current, ...items] : items))
+      setNextCursor(data.nextCursor || '')
     } catch (err) {
       setLinksError(err.message || 'Failed to load links.')
     } finally {
@@ -39,7 +44,7 @@ function Analytics({ formatExpiry, refreshKey }) {
     setLookupError('')
     setLookupResult(null)
     try {
-      const response = await fetch(`/api/links/${encodeURIComponent(code)}`)
+      const response = await apiFetch(`/api/links/${encodeURIComponent(code)}`)
       if (!response.ok) {
         const message = await response.text()
         throw new Error(message || 'Link not found')
@@ -92,7 +97,7 @@ function Analytics({ formatExpiry, refreshKey }) {
             <button
               className="ghost"
               type="button"
-              onClick={fetchLinks}
+              onClick={() => fetchLinks()}
               disabled={linksLoading}
             >
               {linksLoading ? 'Refreshing...' : 'Refresh'}
@@ -109,7 +114,7 @@ function Analytics({ formatExpiry, refreshKey }) {
               <span>Total</span>
               <span>Unique</span>
             </div>
-            {linksLoading ? (
+            {linksLoading && links.length === 0 ? (
               <div className="table-row">
                 <span className="muted">Loading links...</span>
               </div>
@@ -119,7 +124,7 @@ function Analytics({ formatExpiry, refreshKey }) {
               </div>
             ) : (
               links.map((link) => (
-                <div className="table-row" key={link.code}>
+                <div className="table-row" key={`${link.domain || ''}/${link.code}`}>
                   <span className="mono">{link.code}</span>
                   <span className="truncate">{link.originalUrl}</span>
                   <span>{formatExpiry(link.expiresAt)}</span>
@@ -129,6 +134,19 @@ function Analytics({ formatExpiry, refreshKey }) {
               ))
             )}
           </div>
+
+          {nextCursor ? (
+            <div className="analytics-actions">
+              <button
+                className="ghost"
+                type="button"
+                onClick={() => fetchLinks(nextCursor)}
+                disabled={linksLoading}
+              >
+                Load more
+              </button>
+            </div>
+          ) : null}
         </>
       ) : (
         <>
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"link-shortener/internal/auth"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+type principalKey struct{}
+
+// principal is the authenticated caller. user is nil for keys created before
+// user accounts existed; such keys are only accepted with the admin scope.
+type principal struct {
+	key  *model.APIKey
+	user *model.User
+}
+
+func (p *principal) isAdmin() bool {
+	if p.user != nil {
+		return p.user.IsAdmin()
+	}
+	return auth.HasScope(p.key.Scopes, auth.ScopeAdmin)
+}
+
+func (p *principal) userID() int64 {
+	if p.user == nil {
+		return 0
+	}
+	return p.user.ID
+}
+
+func (p *principal) workspaceID() int64 {
+	if p.user == nil {
+		return 0
+	}
+	return p.user.WorkspaceID
+}
+
+// requireAPIKey authenticates the caller and checks the scope that
+// requiredScope assigns to the request. It is a no-op when the server has no
+// key store configured.
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	if s.keys == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := apiKeyFromRequest(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, "missing API key", http.StatusUnauthorized)
+			return
+		}
+		key, err := s.keys.APIKeyByHash(r.Context(), auth.HashKey(token))
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			writeLookupError(w, r, err)
+			return
+		}
+
+		caller := &principal{key: key}
+		if key.UserID != 0 {
+			if s.users == nil {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+			user, err := s.users.UserByID(r.Context(), key.UserID)
+			if errors.Is(err, storage.ErrUserNotFound) {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				writeLookupError(w, r, err)
+				return
+			}
+			caller.user = user
+		} else if !auth.HasScope(key.Scopes, auth.ScopeAdmin) {
+			http.Error(w, "API key is not attached to a user; create a new key", http.StatusForbidden)
+			return
+		}
+
+		scope := requiredScope(r)
+		if !auth.HasScope(key.Scopes, scope) {
+			http.Error(w, "API key lacks scope "+scope, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, caller)))
+	}
+}
+
+func requiredScope(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/api/shorten":
+		return auth.ScopeLinksWrite
+	case path == "/api/links" || strings.HasPrefix(path, "/api/links/"):
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return auth.ScopeAnalyticsRead
+		}
+		return auth.ScopeLinksWrite
+	default:
+		return auth.ScopeAdmin
+	}
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// callerID is the user that owns links created by this request, or 0 when
+// authentication is disabled or the key has no user.
+func callerID(r *http.Request) int64 {
+	if caller, ok := r.Context().Value(principalKey{}).(*principal); ok {
+		return caller.userID()
+	}
+	return 0
+}
+
+// ownerScope is the owner a listing must be restricted to, or 0 when the
+// caller may see every link.
+func ownerScope(r *http.Request) int64 {
+	caller, ok := r.Context().Value(principalKey{}).(*principal)
+	if !ok || caller.isAdmin() {
+		return 0
+	}
+	return caller.userID()
+}
+
+func canAccessLink(r *http.Request, ownerID int64) bool {
+	scope := ownerScope(r)
+	return scope == 0 || scope == ownerID
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/useragent"
+)
+
+// ParseBotNetworks parses a comma-separated list of CIDRs or bare IPs whose
+// clicks count as bots.
+func ParseBotNetworks(raw string) ([]netip.Prefix, error) {
+	return parsePrefixes(raw, "bot network")
+}
+
+// isBotAgent reports crawlers, link unfurlers and HTTP libraries. A missing or
+// unrecognised User-Agent is not evidence of a bot, so those clicks count.
+func isBotAgent(agent useragent.Agent) bool {
+	return agent.Device == useragent.DeviceBot
+}
+
+func (s *Server) isBotNetwork(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range s.botNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIncludeBots(query url.Values) (bool, error) {
+	raw := strings.TrimSpace(query.Get("includeBots"))
+	if raw == "" {
+		return false, nil
+	}
+	includeBots, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("includeBots must be true or false")
+	}
+	return includeBots, nil
+}
+
+// countedLink returns link with the clicks analytics report on: humans only
+// unless includeBots. Stores keep bots out of UniqueIPs, so with bots the
+// visitors are recounted from the clicks.
+func countedLink(link *model.Link, includeBots bool) *model.Link {
+	counted := *link
+	if includeBots {
+		counted.UniqueIPs = make(map[string]struct{}, len(link.UniqueIPs))
+		for ip := range link.UniqueIPs {
+			counted.UniqueIPs[ip] = struct{}{}
+		}
+		for _, click := range link.Clicks {
+			if click.VisitorKey() != "" {
+				counted.UniqueIPs[click.VisitorKey()] = struct{}{}
+			}
+		}
+		return &counted
+	}
+	counted.Clicks = nil
+	for _, click := range link.Clicks {
+		if !click.IsBot {
+			counted.Clicks = append(counted.Clicks, click)
+		}
+	}
+	return &counted
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"testing"
+
+	"link-shortener/internal/useragent"
+)
+
+func TestIsBotAgent(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"curl/8.4.0", true},
+		{"", false},
+		{"SomeApp/3.2 (custom client)", false},
+	}
+	for _, tt := range tests {
+		if got := isBotAgent(useragent.Parse(tt.ua)); got != tt.want {
+			t.Errorf("isBotAgent(%q) = %v, want %v", tt.ua, got, tt.want)
+		}
+	}
+}
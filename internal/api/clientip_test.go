@@ -0,0 +1,215 @@
+package api
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		remote      string
+		headers     map[string]string
+		trustRealIP bool
+		want        string
+	}{
+		{
+			name:   "NoHeaders",
+			remote: "198.51.100.9:4000",
+			want:   "198.51.100.9",
+		},
+		{
+			name:    "UntrustedPeerSpoofsXFF",
+			remote:  "198.51.100.9:4000",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "UntrustedPeerSpoofsForwarded",
+			remote:  "198.51.100.9:4000",
+			headers: map[string]string{"Forwarded": "for=1.2.3.4"},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "TrustedProxy",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.5"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "TrustedHopsSkipped",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.5, 10.0.0.3, 192.0.2.1"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "PrependedEntriesIgnored",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.5, 10.0.0.3"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "EveryHopTrusted",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.2, 10.0.0.3"},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "XFFWithPort",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.5:5555"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "Forwarded",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": "for=192.0.2.60;proto=http;by=203.0.113.43, for=10.0.0.3"},
+			want:    "192.0.2.60",
+		},
+		{
+			name:    "ForwardedQuotedIPv6",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https`},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "ForwardedQuotedSeparators",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": `for=203.0.113.5;host="a,b;c", for="10.0.0.3"`},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "ForwardedCaseInsensitive",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": "For=203.0.113.5"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:   "ForwardedPreferredOverXFF",
+			remote: "10.0.0.1:4000",
+			headers: map[string]string{
+				"Forwarded":       "for=203.0.113.5",
+				"X-Forwarded-For": "203.0.113.6",
+			},
+			want: "203.0.113.5",
+		},
+		{
+			name:    "ForwardedUnknownStopsAtLastTrustedHop",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": "for=unknown, for=10.0.0.3"},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "ForwardedObfuscatedStopsWalk",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"Forwarded": "for=203.0.113.5, for=_hidden"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "GarbledXFF",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "not-an-ip"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:   "RealIPIgnoredByDefault",
+			remote: "10.0.0.1:4000",
+			headers: map[string]string{
+				"X-Real-IP":       "203.0.113.7",
+				"X-Forwarded-For": "203.0.113.5",
+			},
+			want: "203.0.113.5",
+		},
+		{
+			name:   "RealIPFromTrustedProxy",
+			remote: "10.0.0.1:4000",
+			headers: map[string]string{
+				"X-Real-IP":       "203.0.113.7",
+				"X-Forwarded-For": "203.0.113.5",
+			},
+			trustRealIP: true,
+			want:        "203.0.113.7",
+		},
+		{
+			name:        "RealIPFromUntrustedPeer",
+			remote:      "198.51.100.9:4000",
+			headers:     map[string]string{"X-Real-IP": "203.0.113.7"},
+			trustRealIP: true,
+			want:        "198.51.100.9",
+		},
+		{
+			name:        "RealIPInvalidFallsBackToChain",
+			remote:      "10.0.0.1:4000",
+			headers:     map[string]string{"X-Real-IP": "garbage", "X-Forwarded-For": "203.0.113.5"},
+			trustRealIP: true,
+			want:        "203.0.113.5",
+		},
+		{
+			name:    "MappedIPv4Peer",
+			remote:  "[::ffff:10.0.0.1]:4000",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.5"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:   "MappedIPv4UntrustedPeer",
+			remote: "[::ffff:198.51.100.9]:4000",
+			want:   "198.51.100.9",
+		},
+		{
+			name:    "MappedIPv4Hop",
+			remote:  "10.0.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "::ffff:203.0.113.5, ::ffff:10.0.0.3"},
+			want:    "203.0.113.5",
+		},
+		{
+			name:   "IPv6Peer",
+			remote: "[2001:db8::2]:4000",
+			want:   "2001:db8::2",
+		},
+		{
+			name:   "UnparseableRemoteAddr",
+			remote: "pipe",
+			want:   "pipe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: proxies, trustRealIP: tt.trustRealIP}
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies(" 10.0.0.0/8 ,, 192.0.2.1, ::ffff:198.51.100.1, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range prefixes {
+		got = append(got, p.String())
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "198.51.100.1/32", "2001:db8::/32"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("prefixes = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := ParseTrustedProxies(bad); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", bad)
+		}
+	}
+}
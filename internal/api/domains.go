@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+var (
+	errInvalidDomain     = errors.New("domain must be a bare hostname such as go.example.com")
+	errDomainUnavailable = errors.New("domain is not registered to your workspace")
+
+	domainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)+$`)
+)
+
+func normalizeDomain(raw string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		return "", errInvalidDomain
+	}
+	return domain, nil
+}
+
+// requestDomain maps the Host of a redirect to the domain its codes live in.
+// Hosts that no workspace has registered serve the default domain.
+func (s *Server) requestDomain(r *http.Request) (string, error) {
+	if s.workspaces == nil {
+		return "", nil
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	_, err := s.workspaces.WorkspaceByDomain(r.Context(), host)
+	if errors.Is(err, storage.ErrWorkspaceNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return host, nil
+}
+
+// linkDomain picks the domain a new link is created on. Without an explicit
+// choice that is the primary domain of the caller's workspace, or the default
+// domain for callers outside any workspace. Only admins may use domains of
+// other workspaces.
+func (s *Server) linkDomain(r *http.Request, requested string) (string, error) {
+	caller, _ := r.Context().Value(principalKey{}).(*principal)
+	if strings.TrimSpace(requested) == "" {
+		if s.workspaces == nil || caller == nil || caller.workspaceID() == 0 {
+			return "", nil
+		}
+		workspace, err := s.workspaces.WorkspaceByID(r.Context(), caller.workspaceID())
+		if errors.Is(err, storage.ErrWorkspaceNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return workspace.PrimaryDomain(), nil
+	}
+
+	domain, err := normalizeDomain(requested)
+	if err != nil {
+		return "", err
+	}
+	if s.workspaces == nil {
+		return "", errDomainUnavailable
+	}
+	workspace, err := s.workspaces.WorkspaceByDomain(r.Context(), domain)
+	if errors.Is(err, storage.ErrWorkspaceNotFound) {
+		return "", errDomainUnavailable
+	}
+	if err != nil {
+		return "", err
+	}
+	if caller != nil && !caller.isAdmin() && caller.workspaceID() != workspace.ID {
+		return "", errDomainUnavailable
+	}
+	return domain, nil
+}
+
+// queryDomain reads the domain a link lookup refers to from ?domain=,
+// defaulting to the default domain.
+func queryDomain(query url.Values) (string, error) {
+	raw := query.Get("domain")
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	return normalizeDomain(raw)
+}
+
+func (s *Server) shortURL(domain, code string) string {
+	if domain == "" {
+		return s.baseURL + "/" + code
+	}
+	return s.shortScheme + "://" + domain + "/" + code
+}
+
+// defaultHost is the host of BaseURL, which workspaces may not claim.
+func (s *Server) defaultHost() string {
+	parsed, err := url.Parse(s.baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// claimableDomain validates a domain a workspace wants to register. The host
+// of BaseURL is reserved for the default domain.
+func (s *Server) claimableDomain(raw string) (string, error) {
+	domain, err := normalizeDomain(raw)
+	if err != nil {
+		return "", err
+	}
+	if domain == s.defaultHost() {
+		return "", fmt.Errorf("%s is the default short domain", domain)
+	}
+	return domain, nil
+}
+
+func (s *Server) createWorkspace(ctx context.Context, name string, rawDomains []string) (*model.Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	workspace := &model.Workspace{
+		Name:      name,
+		Domains:   []string{},
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, raw := range rawDomains {
+		domain, err := s.claimableDomain(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(workspace.Domains, domain) {
+			workspace.Domains = append(workspace.Domains, domain)
+		}
+	}
+	if err := s.workspaces.CreateWorkspace(ctx, workspace); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
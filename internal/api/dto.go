@@ -1,14 +1,29 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"link-shortener/internal/clicks"
+	"link-shortener/internal/geo"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage/cache"
+)
 
 type shortenRequest struct {
 	URL         string  `json:"url"`
+	Domain      string  `json:"domain"`
 	CustomAlias string  `json:"customAlias"`
 	ExpiresAt   *string `json:"expiresAt"`
 }
 
+type updateLinkRequest struct {
+	URL         *string `json:"url"`
+	CustomAlias *string `json:"customAlias"`
+	ExpiresAt   *string `json:"expiresAt"`
+}
+
 type shortenResponse struct {
+	Domain      string    `json:"domain,omitempty"`
 	Code        string    `json:"code"`
 	ShortURL    string    `json:"shortUrl"`
 	OriginalURL string    `json:"originalUrl"`
@@ -17,7 +32,9 @@ type shortenResponse struct {
 }
 
 type linkOverview struct {
+	Domain         string    `json:"domain,omitempty"`
 	Code           string    `json:"code"`
+	OwnerID        int64     `json:"ownerId,omitempty"`
 	OriginalURL    string    `json:"originalUrl"`
 	CreatedAt      time.Time `json:"createdAt"`
 	ExpiresAt      time.Time `json:"expiresAt"`
@@ -25,15 +42,106 @@ type linkOverview struct {
 	UniqueVisitors int       `json:"uniqueVisitors"`
 }
 
+type generationOverview struct {
+	ID         int64     `json:"id"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	linkOverview
+}
+
+type linkListResponse struct {
+	Items      []linkOverview `json:"items"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
 type linkDetailsResponse struct {
-	Code           string         `json:"code"`
-	ShortURL       string         `json:"shortUrl"`
-	OriginalURL    string         `json:"originalUrl"`
-	CreatedAt      time.Time      `json:"createdAt"`
-	ExpiresAt      time.Time      `json:"expiresAt"`
-	TotalClicks    int            `json:"totalClicks"`
-	UniqueVisitors int            `json:"uniqueVisitors"`
-	LastAccessed   *time.Time     `json:"lastAccessed,omitempty"`
-	CountryCounts  map[string]int `json:"countryCounts"`
-	QRCode         string         `json:"qrCode"`
+	Domain           string         `json:"domain,omitempty"`
+	Code             string         `json:"code"`
+	OwnerID          int64          `json:"ownerId,omitempty"`
+	ShortURL         string         `json:"shortUrl"`
+	OriginalURL      string         `json:"originalUrl"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	ExpiresAt        time.Time      `json:"expiresAt"`
+	TotalClicks      int            `json:"totalClicks"`
+	UniqueVisitors   int            `json:"uniqueVisitors"`
+	LastAccessed     *time.Time     `json:"lastAccessed,omitempty"`
+	CountryCounts    map[string]int `json:"countryCounts"`
+	TopReferrers     []namedCount   `json:"topReferrers"`
+	ReferrerDomains  []namedCount   `json:"referrerDomains"`
+	Browsers         []namedCount   `json:"browsers"`
+	OperatingSystems []namedCount   `json:"operatingSystems"`
+	Devices          []namedCount   `json:"devices"`
+	QRCode           string         `json:"qrCode"`
+}
+
+type namedCount struct {
+	Name   string `json:"name"`
+	Clicks int    `json:"clicks"`
+}
+
+type referrersResponse struct {
+	Domain      string       `json:"domain,omitempty"`
+	Code        string       `json:"code"`
+	TotalClicks int          `json:"totalClicks"`
+	Referrers   []namedCount `json:"referrers"`
+	Domains     []namedCount `json:"domains"`
+}
+
+type generationDetailsResponse struct {
+	ID         int64     `json:"id"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	linkDetailsResponse
+}
+
+type timeseriesResponse struct {
+	Domain      string             `json:"domain,omitempty"`
+	Code        string             `json:"code"`
+	Granularity string             `json:"granularity"`
+	Timezone    string             `json:"timezone"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Buckets     []timeseriesBucket `json:"buckets"`
+}
+
+type timeseriesBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	UserID int64    `json:"userId"`
+	Scopes []string `json:"scopes"`
+}
+
+type createUserRequest struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	WorkspaceID int64  `json:"workspaceId"`
+}
+
+type createWorkspaceRequest struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+}
+
+type addDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+type createAPIKeyResponse struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+type metricsResponse struct {
+	RedirectCache *cache.Stats     `json:"redirectCache,omitempty"`
+	Clicks        clicks.Stats     `json:"clicks"`
+	GeoCache      *geo.CacheStats  `json:"geoCache,omitempty"`
+	RateLimits    rateLimitMetrics `json:"rateLimitClients"`
+}
+
+type rateLimitMetrics struct {
+	Shorten   int `json:"shorten"`
+	Analytics int `json:"analytics"`
+	Redirect  int `json:"redirect"`
 }
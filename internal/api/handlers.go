@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"link-shortener/internal/auth"
+	"link-shortener/internal/geo"
 	"link-shortener/internal/model"
 	"link-shortener/internal/storage"
 )
@@ -31,13 +35,13 @@ func (s *Server) handleShorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	code, err := s.resolveCode(payload.CustomAlias)
+	domain, err := s.linkDomain(r, payload.Domain)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if errors.Is(err, storage.ErrCodeExists) || errors.Is(err, errInvalidCustomCode) {
-			status = http.StatusBadRequest
+		if errors.Is(err, errInvalidDomain) || errors.Is(err, errDomainUnavailable) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		http.Error(w, err.Error(), status)
+		writeLookupError(w, r, err)
 		return
 	}
 
@@ -48,18 +52,23 @@ func (s *Server) handleShorten(w http.ResponseWriter, r *http.Request) {
 	}
 
 	link := &model.Link{
-		Code:        code,
+		Domain:      domain,
+		OwnerID:     callerID(r),
 		OriginalURL: originalURL,
 		CreatedAt:   time.Now().UTC(),
 		ExpiresAt:   expiresAt,
 	}
-
-	if err := s.saveOrReplaceLink(link); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := s.saveLink(r.Context(), link, payload.CustomAlias); err != nil {
+		if errors.Is(err, errInvalidCustomCode) || errors.Is(err, errAliasInUse) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("failed to store link: %v", err)
+		http.Error(w, "failed to store link", http.StatusInternalServerError)
 		return
 	}
 
-	shortURL := fmt.Sprintf("%s/%s", s.baseURL, code)
+	shortURL := s.shortURL(domain, link.Code)
 	qrData, err := generateQRCodeDataURL(shortURL)
 	if err != nil {
 		http.Error(w, "failed to generate QR code", http.StatusInternalServerError)
@@ -67,7 +76,8 @@ func (s *Server) handleShorten(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusCreated, shortenResponse{
-		Code:        code,
+		Domain:      domain,
+		Code:        link.Code,
 		ShortURL:    shortURL,
 		OriginalURL: originalURL,
 		ExpiresAt:   expiresAt,
@@ -81,42 +91,107 @@ func (s *Server) handleListLinks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	links := s.store.List()
-	items := make([]linkOverview, 0, len(links))
-	for _, link := range links {
-		items = append(items, linkOverview{
-			Code:           link.Code,
-			OriginalURL:    link.OriginalURL,
-			CreatedAt:      link.CreatedAt,
-			ExpiresAt:      link.ExpiresAt,
-			TotalClicks:    len(link.Clicks),
-			UniqueVisitors: len(link.UniqueIPs),
-		})
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts.OwnerID = ownerScope(r)
+	page, err := s.store.List(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to list links", http.StatusInternalServerError)
+		return
 	}
-	writeJSON(w, http.StatusOK, items)
+
+	items := make([]linkOverview, 0, len(page.Links))
+	for _, link := range page.Links {
+		items = append(items, buildLinkOverview(link))
+	}
+	writeJSON(w, http.StatusOK, linkListResponse{
+		Items:      items,
+		NextCursor: page.NextCursor,
+	})
 }
 
-func (s *Server) handleLinkDetails(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleLink(w http.ResponseWriter, r *http.Request) {
 	if !strings.HasPrefix(r.URL.Path, "/api/links/") {
 		http.NotFound(w, r)
 		return
 	}
 
-	code := strings.TrimPrefix(r.URL.Path, "/api/links/")
+	code, rest, nested := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/links/"), "/")
 	if code == "" {
 		http.NotFound(w, r)
 		return
 	}
-	link, ok := s.store.Get(code)
-	if !ok {
+	domain, err := queryDomain(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Generations outlive the current link, so they skip the check below.
+	if nested && (rest == "generations" || strings.HasPrefix(rest, "generations/")) {
+		s.handleGenerations(w, r, domain, code, rest)
+		return
+	}
+
+	// Links owned by someone else are reported as missing rather than
+	// forbidden so codes can't be probed.
+	target, err := s.store.Resolve(r.Context(), domain, code)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	if !canAccessLink(r, target.OwnerID) {
 		http.NotFound(w, r)
 		return
 	}
+
+	if nested {
+		switch rest {
+		case "timeseries":
+			s.handleTimeseries(w, r, domain, code)
+		case "referrers":
+			s.handleReferrers(w, r, domain, code)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleLinkDetails(w, r, domain, code)
+	case http.MethodPatch:
+		s.handleUpdateLink(w, r, domain, code)
+	case http.MethodDelete:
+		s.handleDeleteLink(w, r, domain, code)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleLinkDetails(w http.ResponseWriter, r *http.Request, domain, code string) {
+	includeBots, err := parseIncludeBots(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	link, err := s.store.Get(r.Context(), domain, code)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
 	if time.Now().After(link.ExpiresAt) {
 		http.Error(w, "link has expired", http.StatusGone)
 		return
 	}
-	resp, err := buildLinkDetails(link, s.baseURL)
+	resp, err := buildLinkDetails(countedLink(link, includeBots), s.shortURL(link.Domain, link.Code))
 	if err != nil {
 		http.Error(w, "failed to build link response", http.StatusInternalServerError)
 		return
@@ -124,6 +199,196 @@ func (s *Server) handleLinkDetails(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+func (s *Server) handleUpdateLink(w http.ResponseWriter, r *http.Request, domain, code string) {
+	var payload updateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	update, err := parseLinkUpdate(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.store.Update(r.Context(), domain, code, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			http.NotFound(w, r)
+		case errors.Is(err, storage.ErrCodeExists):
+			http.Error(w, "customAlias already in use", http.StatusBadRequest)
+		default:
+			http.Error(w, "failed to update link", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp, err := buildLinkDetails(countedLink(link, false), s.shortURL(link.Domain, link.Code))
+	if err != nil {
+		http.Error(w, "failed to build link response", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleDeleteLink(w http.ResponseWriter, r *http.Request, domain, code string) {
+	if err := s.store.Delete(r.Context(), domain, code); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleTimeseries(w http.ResponseWriter, r *http.Request, domain, code string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseTimeseriesQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buckets, err := query.buckets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	counts, err := s.store.ClickTimeseries(r.Context(), domain, code, buckets, query.includeBots)
+	if err != nil {
+		http.Error(w, "failed to load timeseries", http.StatusInternalServerError)
+		return
+	}
+
+	resp := timeseriesResponse{
+		Domain:      domain,
+		Code:        code,
+		Granularity: query.granularity,
+		Timezone:    query.location.String(),
+		From:        buckets[0].Start,
+		To:          buckets[len(buckets)-1].End,
+		Buckets:     make([]timeseriesBucket, len(buckets)),
+	}
+	for i, bucket := range buckets {
+		resp.Buckets[i] = timeseriesBucket{Start: bucket.Start, Clicks: counts[i]}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleReferrers(w http.ResponseWriter, r *http.Request, domain, code string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultListLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	includeBots, err := parseIncludeBots(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.store.Get(r.Context(), domain, code)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	if time.Now().After(link.ExpiresAt) {
+		http.Error(w, "link has expired", http.StatusGone)
+		return
+	}
+	link = countedLink(link, includeBots)
+	referrers, domains := countReferrers(link.Clicks, limit)
+	writeJSON(w, http.StatusOK, referrersResponse{
+		Domain:      link.Domain,
+		Code:        link.Code,
+		TotalClicks: len(link.Clicks),
+		Referrers:   referrers,
+		Domains:     domains,
+	})
+}
+
+// handleGenerations serves the archived generations of a code. They outlive
+// the current link, and callers only see the generations they owned.
+func (s *Server) handleGenerations(w http.ResponseWriter, r *http.Request, domain, code, rest string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	includeBots, err := parseIncludeBots(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rest == "generations" {
+		generations, err := s.store.ListGenerations(r.Context(), domain, code, includeBots)
+		if err != nil {
+			http.Error(w, "failed to list generations", http.StatusInternalServerError)
+			return
+		}
+		items := make([]generationOverview, 0, len(generations))
+		for _, generation := range generations {
+			if !canAccessLink(r, generation.OwnerID) {
+				continue
+			}
+			items = append(items, generationOverview{
+				ID:           generation.ID,
+				ArchivedAt:   generation.ArchivedAt,
+				linkOverview: buildLinkOverview(generation.LinkSummary),
+			})
+		}
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+
+	idPart, ok := strings.CutPrefix(rest, "generations/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	generation, err := s.store.Generation(r.Context(), domain, code, id)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	if !canAccessLink(r, generation.OwnerID) {
+		http.NotFound(w, r)
+		return
+	}
+	details, err := buildLinkDetails(countedLink(&generation.Link, includeBots), s.shortURL(domain, code))
+	if err != nil {
+		http.Error(w, "failed to build link response", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, generationDetailsResponse{
+		ID:                  generation.ID,
+		ArchivedAt:          generation.ArchivedAt,
+		linkDetailsResponse: details,
+	})
+}
+
 func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
 	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/" {
 		http.NotFound(w, r)
@@ -136,33 +401,319 @@ func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	link, ok := s.store.Get(code)
-	if !ok {
-		http.NotFound(w, r)
+	domain, err := s.requestDomain(r)
+	if err != nil {
+		writeLookupError(w, r, err)
 		return
 	}
-	if time.Now().After(link.ExpiresAt) {
+	target, err := s.store.Resolve(r.Context(), domain, code)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	if time.Now().After(target.ExpiresAt) {
 		http.Error(w, "link has expired", http.StatusGone)
 		return
 	}
 
-	ip := clientIP(r)
 	click := model.Click{
 		Timestamp: time.Now().UTC(),
-		IP:        ip,
-		Country:   detectCountry(ip),
+		IP:        s.clientIP(r),
 		UserAgent: r.UserAgent(),
+		Referrer:  normalizeReferrer(r.Referer()),
+		// Link scanners and monitors probe with HEAD; browsers follow with GET.
+		IsBot: r.Method == http.MethodHead,
 	}
+	s.clicks.Record(r.Context(), domain, code, click)
+
+	http.Redirect(w, r, target.OriginalURL, http.StatusFound)
+}
 
-	if _, err := s.store.RecordClick(code, click); err != nil && !errors.Is(err, storage.ErrNotFound) {
-		log.Printf("failed to record click for %s: %v", code, err)
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	http.Redirect(w, r, link.OriginalURL, http.StatusFound)
+	resp := metricsResponse{
+		Clicks: s.clicks.Stats(),
+		RateLimits: rateLimitMetrics{
+			Shorten:   s.limits.shorten.size(),
+			Analytics: s.limits.analytics.size(),
+			Redirect:  s.limits.redirect.size(),
+		},
+	}
+	if s.cache != nil {
+		stats := s.cache.Stats()
+		resp.RedirectCache = &stats
+	}
+	if c, ok := s.geo.(*geo.Cache); ok {
+		stats := c.Stats()
+		resp.GeoCache = &stats
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
-func buildLinkDetails(link *model.Link, baseURL string) (linkDetailsResponse, error) {
-	shortURL := fmt.Sprintf("%s/%s", baseURL, link.Code)
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Members manage only their own keys and cannot hand out the admin scope.
+	caller, _ := r.Context().Value(principalKey{}).(*principal)
+	member := caller != nil && !caller.isAdmin()
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.keys.ListAPIKeys(r.Context())
+		if err != nil {
+			http.Error(w, "failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		if member {
+			keys = slices.DeleteFunc(keys, func(k model.APIKey) bool { return k.UserID != caller.userID() })
+		}
+		if keys == nil {
+			keys = []model.APIKey{}
+		}
+		writeJSON(w, http.StatusOK, keys)
+	case http.MethodPost:
+		var payload createAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		userID := payload.UserID
+		if userID == 0 {
+			userID = callerID(r)
+		}
+		if member && userID != caller.userID() {
+			http.Error(w, "only admins may issue keys for other users", http.StatusForbidden)
+			return
+		}
+		if member && slices.Contains(payload.Scopes, auth.ScopeAdmin) {
+			http.Error(w, "only admins may issue admin keys", http.StatusForbidden)
+			return
+		}
+		if userID != 0 {
+			if _, err := s.users.UserByID(r.Context(), userID); err != nil {
+				if errors.Is(err, storage.ErrUserNotFound) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				writeLookupError(w, r, err)
+				return
+			}
+		}
+		token, key, err := IssueAPIKey(r.Context(), s.keys, userID, payload.Name, payload.Scopes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, createAPIKeyResponse{APIKey: *key, Key: token})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	if s.keys == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/keys/"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	// Other users' keys look missing to members.
+	if caller, _ := r.Context().Value(principalKey{}).(*principal); caller != nil && !caller.isAdmin() {
+		keys, err := s.keys.ListAPIKeys(r.Context())
+		if err != nil {
+			http.Error(w, "failed to delete API key", http.StatusInternalServerError)
+			return
+		}
+		if !slices.ContainsFunc(keys, func(k model.APIKey) bool { return k.ID == id && k.UserID == caller.userID() }) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if err := s.keys.DeleteAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to delete API key", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if s.users == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if caller, _ := r.Context().Value(principalKey{}).(*principal); caller != nil && !caller.isAdmin() {
+		http.Error(w, "admin role required", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		users, err := s.users.ListUsers(r.Context())
+		if err != nil {
+			http.Error(w, "failed to list users", http.StatusInternalServerError)
+			return
+		}
+		if users == nil {
+			users = []model.User{}
+		}
+		writeJSON(w, http.StatusOK, users)
+	case http.MethodPost:
+		var payload createUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		if payload.WorkspaceID != 0 {
+			if s.workspaces == nil {
+				http.Error(w, storage.ErrWorkspaceNotFound.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, err := s.workspaces.WorkspaceByID(r.Context(), payload.WorkspaceID); err != nil {
+				if errors.Is(err, storage.ErrWorkspaceNotFound) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				writeLookupError(w, r, err)
+				return
+			}
+		}
+		user, err := CreateUser(r.Context(), s.users, payload.Name, payload.Role, payload.WorkspaceID)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, storage.ErrUserExists) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, http.StatusCreated, user)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if s.workspaces == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		workspaces, err := s.workspaces.ListWorkspaces(r.Context())
+		if err != nil {
+			http.Error(w, "failed to list workspaces", http.StatusInternalServerError)
+			return
+		}
+		if workspaces == nil {
+			workspaces = []model.Workspace{}
+		}
+		writeJSON(w, http.StatusOK, workspaces)
+	case http.MethodPost:
+		var payload createWorkspaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		workspace, err := s.createWorkspace(r.Context(), payload.Name, payload.Domains)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, storage.ErrWorkspaceExists) || errors.Is(err, storage.ErrDomainExists) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, http.StatusCreated, workspace)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleWorkspaceDomains(w http.ResponseWriter, r *http.Request) {
+	if s.workspaces == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/workspaces/")
+	idPart, ok := strings.CutSuffix(rest, "/domains")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload addDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	domain, err := s.claimableDomain(payload.Domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.workspaces.AddDomain(r.Context(), id, domain); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWorkspaceNotFound):
+			http.NotFound(w, r)
+		case errors.Is(err, storage.ErrDomainExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "failed to add domain", http.StatusInternalServerError)
+		}
+		return
+	}
+	workspace, err := s.workspaces.WorkspaceByID(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, workspace)
+}
+
+func buildLinkOverview(link model.LinkSummary) linkOverview {
+	return linkOverview{
+		Domain:         link.Domain,
+		Code:           link.Code,
+		OwnerID:        link.OwnerID,
+		OriginalURL:    link.OriginalURL,
+		CreatedAt:      link.CreatedAt,
+		ExpiresAt:      link.ExpiresAt,
+		TotalClicks:    link.TotalClicks,
+		UniqueVisitors: link.UniqueVisitors,
+	}
+}
+
+func buildLinkDetails(link *model.Link, shortURL string) (linkDetailsResponse, error) {
 	var lastAccessed *time.Time
 	if n := len(link.Clicks); n > 0 {
 		t := link.Clicks[n-1].Timestamp
@@ -176,39 +727,29 @@ func buildLinkDetails(link *model.Link, baseURL string) (linkDetailsResponse, er
 		}
 		countryCounts[country]++
 	}
+	referrers, referrerDomains := countReferrers(link.Clicks, topReferrers)
+	browsers, systems, devices := countAgents(link.Clicks)
 	qr, err := generateQRCodeDataURL(shortURL)
 	if err != nil {
 		return linkDetailsResponse{}, err
 	}
 	return linkDetailsResponse{
-		Code:           link.Code,
-		ShortURL:       shortURL,
-		OriginalURL:    link.OriginalURL,
-		CreatedAt:      link.CreatedAt,
-		ExpiresAt:      link.ExpiresAt,
-		TotalClicks:    len(link.Clicks),
-		UniqueVisitors: len(link.UniqueIPs),
-		LastAccessed:   lastAccessed,
-		CountryCounts:  countryCounts,
-		QRCode:         qr,
+		Domain:           link.Domain,
+		Code:             link.Code,
+		OwnerID:          link.OwnerID,
+		ShortURL:         shortURL,
+		OriginalURL:      link.OriginalURL,
+		CreatedAt:        link.CreatedAt,
+		ExpiresAt:        link.ExpiresAt,
+		TotalClicks:      len(link.Clicks),
+		UniqueVisitors:   len(link.UniqueIPs),
+		LastAccessed:     lastAccessed,
+		CountryCounts:    countryCounts,
+		TopReferrers:     referrers,
+		ReferrerDomains:  referrerDomains,
+		Browsers:         browsers,
+		OperatingSystems: systems,
+		Devices:          devices,
+		QRCode:           qr,
 	}, nil
 }
-func (s *Server) saveOrReplaceLink(link *model.Link) error {
-	if err := s.store.Save(link); err == nil {
-		return nil
-	} else if !errors.Is(err, storage.ErrCodeExists) {
-		return fmt.Errorf("failed to store link: %w", err)
-	}
-
-	existing, ok := s.store.Get(link.Code)
-	if !ok {
-		return fmt.Errorf("alias conflict")
-	}
-	if time.Now().After(existing.ExpiresAt) {
-		if err := s.store.Upsert(link); err != nil {
-			return fmt.Errorf("failed to overwrite expired link: %w", err)
-		}
-		return nil
-	}
-	return errors.New("customAlias already in use")
-}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"link-shortener/internal/auth"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/memory"
+	"link-shortener/internal/storage/sqlite"
+)
+
+func newTestServer(t *testing.T, store storage.Store) *Server {
+	t.Helper()
+	s := NewServer(Config{Store: store, BaseURL: "http://sho.rt"})
+	t.Cleanup(func() { s.Close(t.Context()) })
+	return s
+}
+
+// TestShortenConcurrentAlias races requests for one custom alias: exactly one
+// creates it and every other caller is told the alias is taken.
+func TestShortenConcurrentAlias(t *testing.T) {
+	stores := map[string]func(t *testing.T) storage.Store{
+		"memory": func(t *testing.T) storage.Store { return memory.New() },
+		"sqlite": func(t *testing.T) storage.Store {
+			s, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			handler := newTestServer(t, newStore(t)).Routes()
+
+			const requests = 32
+			type response struct {
+				code int
+				body string
+			}
+			responses := make(chan response, requests)
+			var wg sync.WaitGroup
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest(http.MethodPost, "/api/shorten",
+						strings.NewReader(`{"url":"https://example.com","customAlias":"launch"}`))
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, req)
+					responses <- response{rec.Code, strings.TrimSpace(rec.Body.String())}
+				}()
+			}
+			wg.Wait()
+			close(responses)
+
+			created := 0
+			for resp := range responses {
+				switch resp.code {
+				case http.StatusCreated:
+					created++
+				case http.StatusBadRequest:
+					if resp.body != errAliasInUse.Error() {
+						t.Errorf("400 body = %q, want %q", resp.body, errAliasInUse)
+					}
+				default:
+					t.Errorf("status %d: %s", resp.code, resp.body)
+				}
+			}
+			if created != 1 {
+				t.Fatalf("%d requests created the alias, want 1", created)
+			}
+		})
+	}
+}
+
+// TestMemberCannotEscalate gives a member an admin-scope key: the scope opens
+// key and user management, but the member's role keeps them to their own keys.
+func TestMemberCannotEscalate(t *testing.T) {
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(Config{Store: store, APIKeys: store, Users: store, BaseURL: "http://sho.rt"})
+	t.Cleanup(func() { s.Close(t.Context()) })
+	handler := s.Routes()
+	ctx := t.Context()
+
+	admin, err := CreateUser(ctx, store, "root", model.RoleAdmin, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := CreateUser(ctx, store, "mallory", model.RoleMember, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, adminKey, err := IssueAPIKey(ctx, store, admin.ID, "root", []string{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memberToken, _, err := IssueAPIKey(ctx, store, member.ID, "mallory", []string{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	denied := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/keys", fmt.Sprintf(`{"name":"takeover","userId":%d,"scopes":["links:write"]}`, admin.ID), http.StatusForbidden},
+		{http.MethodPost, "/api/keys", `{"name":"takeover","scopes":["admin"]}`, http.StatusForbidden},
+		{http.MethodDelete, fmt.Sprintf("/api/keys/%d", adminKey.ID), "", http.StatusNotFound},
+		{http.MethodGet, "/api/users", "", http.StatusForbidden},
+		{http.MethodPost, "/api/users", `{"name":"eve","role":"admin"}`, http.StatusForbidden},
+	}
+	for _, tt := range denied {
+		if rec := do(memberToken, tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("member %s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec := do(memberToken, http.MethodPost, "/api/keys", `{"name":"ci","scopes":["links:write"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("member issuing own key = %d: %s", rec.Code, rec.Body)
+	}
+	var issued createAPIKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued.UserID != member.ID {
+		t.Fatalf("key issued for user %d, want %d", issued.UserID, member.ID)
+	}
+
+	rec = do(memberToken, http.MethodGet, "/api/keys", "")
+	var listed []model.APIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range listed {
+		if k.UserID != member.ID {
+			t.Errorf("member listed key %d of user %d", k.ID, k.UserID)
+		}
+	}
+	if len(listed) != 2 {
+		t.Errorf("member listed %d keys, want their 2", len(listed))
+	}
+
+	if rec := do(memberToken, http.MethodDelete, fmt.Sprintf("/api/keys/%d", issued.ID), ""); rec.Code != http.StatusNoContent {
+		t.Errorf("member deleting own key = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(adminToken, http.MethodPost, "/api/users", `{"name":"ops","role":"admin"}`); rec.Code != http.StatusCreated {
+		t.Errorf("admin creating user = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// TestReferrersExpired checks that referrers, like the link details, are gone
+// once the link expires.
+func TestReferrersExpired(t *testing.T) {
+	store := memory.New()
+	handler := newTestServer(t, store).Routes()
+	now := time.Now()
+	for code, expires := range map[string]time.Time{"live": now.Add(time.Hour), "stale": now.Add(-time.Hour)} {
+		link := &model.Link{Code: code, OriginalURL: "https://example.com", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: expires}
+		if err := store.Save(t.Context(), link); err != nil {
+			t.Fatal(err)
+		}
+		click := model.Click{Timestamp: now.Add(-90 * time.Minute), IP: "203.0.113.7", Referrer: "news.example.com/post"}
+		if err := store.RecordClick(t.Context(), "", code, click); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for code, want := range map[string]int{"live": http.StatusOK, "stale": http.StatusGone} {
+		for _, path := range []string{"/api/links/" + code, "/api/links/" + code + "/referrers"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != want {
+				t.Errorf("GET %s = %d, want %d: %s", path, rec.Code, want, rec.Body)
+			}
+		}
+	}
+}
@@ -6,51 +6,74 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	qrcode "github.com/skip2/go-qrcode"
 
+	"link-shortener/internal/auth"
+	"link-shortener/internal/model"
 	"link-shortener/internal/shortcode"
 	"link-shortener/internal/storage"
 )
 
-var errInvalidCustomCode = errors.New("customAlias must be 3-30 characters (letters, numbers, underscores, hyphens)")
+var (
+	errInvalidCustomCode = errors.New("customAlias must be 3-30 characters (letters, numbers, underscores, hyphens)")
+	errAliasInUse        = errors.New("customAlias already in use")
+)
 
-func (s *Server) resolveCode(custom string) (string, error) {
-	code := strings.TrimSpace(custom)
-	if code != "" {
+// saveLink stores link under the custom alias, taking it over if the link
+// holding it has expired, or under a generated code. Both go straight to the
+// store, whose unique key decides concurrent claims; generated codes are
+// retried on collision.
+func (s *Server) saveLink(ctx context.Context, link *model.Link, customAlias string) error {
+	if code := strings.TrimSpace(customAlias); code != "" {
 		if !codePattern.MatchString(code) {
-			return "", errInvalidCustomCode
+			return errInvalidCustomCode
 		}
-		if _, exists := s.store.Get(code); exists {
-			return "", storage.ErrCodeExists
+		link.Code = code
+		err := s.store.ReplaceExpired(ctx, link)
+		if errors.Is(err, storage.ErrCodeExists) {
+			return errAliasInUse
 		}
-		return code, nil
+		return err
 	}
-	return s.generateUniqueCode()
-}
 
-func (s *Server) generateUniqueCode() (string, error) {
 	for attempts := 0; attempts < 5; attempts++ {
 		code, err := shortcode.Generate(minCodeLength, maxCodeLength)
 		if err != nil {
-			return "", err
+			return err
 		}
-		if _, exists := s.store.Get(code); !exists {
-			return code, nil
+		link.Code = code
+		if err := s.store.Save(ctx, link); !errors.Is(err, storage.ErrCodeExists) {
+			return err
 		}
 	}
-	return "", fmt.Errorf("unable to find unique code after several attempts")
+	return fmt.Errorf("unable to find unique code after several attempts")
+}
+
+// writeLookupError answers a failed store lookup: 404 when the record does
+// not exist, 500 when the store could not be read.
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	if isNotFound(err) {
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	http.Error(w, "storage unavailable", http.StatusInternalServerError)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, storage.ErrNotFound) ||
+		errors.Is(err, storage.ErrKeyNotFound) ||
+		errors.Is(err, storage.ErrUserNotFound) ||
+		errors.Is(err, storage.ErrWorkspaceNotFound)
 }
 
 func validateURL(raw string) (string, error) {
@@ -70,6 +93,62 @@ func validateURL(raw string) (string, error) {
 	return parsed.String(), nil
 }
 
+// IssueAPIKey creates a key with the given scopes and returns the plaintext
+// token, which is not stored anywhere and cannot be recovered later.
+func IssueAPIKey(ctx context.Context, keys storage.APIKeyStore, userID int64, name string, scopes []string) (string, *model.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope %q (valid: %s)", scope, strings.Join(auth.Scopes, ", "))
+		}
+	}
+
+	token, err := auth.GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    auth.DisplayPrefix(token),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := keys.CreateAPIKey(ctx, key, auth.HashKey(token)); err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	return token, key, nil
+}
+
+func CreateUser(ctx context.Context, users storage.UserStore, name, role string, workspaceID int64) (*model.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if role == "" {
+		role = model.RoleMember
+	}
+	if role != model.RoleMember && role != model.RoleAdmin {
+		return nil, fmt.Errorf("role must be %s or %s", model.RoleMember, model.RoleAdmin)
+	}
+	user := &model.User{
+		Name:        name,
+		Role:        role,
+		WorkspaceID: workspaceID,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -94,194 +173,142 @@ func parseExpiresAt(raw *string) (time.Time, error) {
 	return t.UTC(), nil
 }
 
-var (
-	codePattern        = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,30}$`)
-	defaultGeoEndpoint = "https://ipapi.co/%s/country/"
-	geoCacheTTL        = time.Hour
-	geoHTTPClient      = &http.Client{Timeout: 3 * time.Second}
-	geoCache           = struct {
-		sync.Mutex
-		data map[string]geoCacheEntry
-	}{data: make(map[string]geoCacheEntry)}
-)
-
-type geoCacheEntry struct {
-	country string
-	expires time.Time
-}
-
-func jsonMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r)
-	})
-}
-
-func generateQRCodeDataURL(content string) (string, error) {
-	png, err := qrcode.Encode(content, qrcode.Medium, 256)
-	if err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(png)), nil
-}
-
-func detectCountry(ip string) string {
-	if ip == "" {
-		return "Unknown"
-	}
-	if country, ok := cachedCountry(ip); ok {
-		return country
+func parseLinkUpdate(payload updateLinkRequest) (storage.LinkUpdate, error) {
+	var update storage.LinkUpdate
+	if payload.URL != nil {
+		originalURL, err := validateURL(*payload.URL)
+		if err != nil {
+			return update, fmt.Errorf("invalid url: %v", err)
+		}
+		update.OriginalURL = &originalURL
 	}
-	if country, err := fetchCountryByIP(ip); err == nil && country != "" {
-		storeCountry(ip, country)
-		return country
+	if payload.CustomAlias != nil {
+		code := strings.TrimSpace(*payload.CustomAlias)
+		if !codePattern.MatchString(code) {
+			return update, errInvalidCustomCode
+		}
+		update.Code = &code
 	}
-	return "Unknown"
-}
-
-func cachedCountry(ip string) (string, bool) {
-	geoCache.Lock()
-	defer geoCache.Unlock()
-
-	entry, ok := geoCache.data[ip]
-	if !ok {
-		return "", false
+	if payload.ExpiresAt != nil {
+		if strings.TrimSpace(*payload.ExpiresAt) == "" {
+			return update, errors.New("expiresAt must be RFC3339 timestamp")
+		}
+		expiresAt, err := parseExpiresAt(payload.ExpiresAt)
+		if err != nil {
+			return update, err
+		}
+		update.ExpiresAt = &expiresAt
 	}
-	if time.Now().After(entry.expires) {
-		delete(geoCache.data, ip)
-		return "", false
+	if update.OriginalURL == nil && update.Code == nil && update.ExpiresAt == nil {
+		return update, errors.New("at least one of url, customAlias or expiresAt is required")
 	}
-	return entry.country, true
+	return update, nil
 }
 
-func storeCountry(ip, country string) {
-	if country == "" {
-		return
-	}
-	geoCache.Lock()
-	defer geoCache.Unlock()
-	geoCache.data[ip] = geoCacheEntry{
-		country: country,
-		expires: time.Now().Add(geoCacheTTL),
+func parseListOptions(query url.Values) (storage.ListOptions, error) {
+	opts := storage.ListOptions{
+		Limit:      defaultListLimit,
+		Cursor:     strings.TrimSpace(query.Get("cursor")),
+		Search:     strings.TrimSpace(query.Get("q")),
+		SortBy:     storage.SortCreated,
+		Descending: true,
 	}
-}
 
-func fetchCountryByIP(ip string) (string, error) {
-	endpoint := geoEndpoint()
-	var lookupURL string
-	if strings.Contains(endpoint, "%s") {
-		lookupURL = fmt.Sprintf(endpoint, url.PathEscape(ip))
-	} else {
-		lookupURL = fmt.Sprintf("%s/%s", strings.TrimSuffix(endpoint, "/"), url.PathEscape(ip))
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		opts.Limit = limit
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
-	if err != nil {
-		return "", err
+	switch status := storage.LinkStatus(strings.TrimSpace(query.Get("status"))); status {
+	case storage.StatusAny, storage.StatusActive, storage.StatusExpired:
+		opts.Status = status
+	default:
+		return opts, errors.New("status must be active or expired")
 	}
 
-	resp, err := geoHTTPClient.Do(req)
-	if err != nil {
-		return "", err
+	if raw := strings.TrimSpace(query.Get("sort")); raw != "" {
+		switch sort := storage.SortField(raw); sort {
+		case storage.SortCreated, storage.SortExpires, storage.SortClicks, storage.SortUniqueVisitors:
+			opts.SortBy = sort
+		default:
+			return opts, errors.New("sort must be one of created, expires, clicks, unique")
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= http.StatusBadRequest {
-		return "", fmt.Errorf("geo lookup failed: %s", resp.Status)
+	// An empty ?domain= restricts the listing to the default domain.
+	if query.Has("domain") {
+		domain, err := queryDomain(query)
+		if err != nil {
+			return opts, err
+		}
+		opts.Domain = &domain
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	includeBots, err := parseIncludeBots(query)
 	if err != nil {
-		return "", err
-	}
-	country := strings.TrimSpace(string(body))
-	if country == "" {
-		return "", errors.New("empty geo response")
-	}
-	return country, nil
-}
-
-func geoEndpoint() string {
-	if val := strings.TrimSpace(os.Getenv("GEOIP_ENDPOINT")); val != "" {
-		return val
-	}
-	return defaultGeoEndpoint
-}
-
-func clientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		if ip := strings.TrimSpace(parts[0]); ip != "" {
-			return ip
+		return opts, err
+	}
+	opts.IncludeBots = includeBots
+
+	switch strings.TrimSpace(query.Get("order")) {
+	case "", "desc":
+		opts.Descending = true
+	case "asc":
+		opts.Descending = false
+	default:
+		return opts, errors.New("order must be asc or desc")
+	}
+
+	timeFilters := []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"createdAfter", &opts.CreatedAfter},
+		{"createdBefore", &opts.CreatedBefore},
+		{"expiresAfter", &opts.ExpiresAfter},
+		{"expiresBefore", &opts.ExpiresBefore},
+	}
+	for _, filter := range timeFilters {
+		raw := strings.TrimSpace(query.Get(filter.param))
+		if raw == "" {
+			continue
 		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return opts, fmt.Errorf("%s must be RFC3339 timestamp", filter.param)
+		}
+		*filter.dest = t.UTC()
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
+	return opts, nil
 }
 
-func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
-	if s.limiter == nil {
-		return next
-	}
+var codePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,30}$`)
+
+func jsonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := clientIP(r)
-		allowed, retry := s.limiter.Allow(ip)
-		if !allowed {
-			if retry > 0 {
-				seconds := int((retry + time.Second - 1) / time.Second)
-				w.Header().Set("Retry-After", strconv.Itoa(seconds))
-			}
-			http.Error(w, "rate limit exceeded: maximum 10 requests per minute", http.StatusTooManyRequests)
-			return
-		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-type rateLimiter struct {
-	mu      sync.Mutex
-	limit   int
-	window  time.Duration
-	clients map[string]*clientWindow
-}
-
-type clientWindow struct {
-	count   int
-	expires time.Time
-}
-
-func newRateLimiter(limit int, window time.Duration) *rateLimiter {
-	return &rateLimiter{
-		limit:   limit,
-		window:  window,
-		clients: make(map[string]*clientWindow),
+func generateQRCodeDataURL(content string) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, 256)
+	if err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(png)), nil
 }
 
-func (rl *rateLimiter) Allow(key string) (bool, time.Duration) {
-	if rl == nil {
-		return true, 0
-	}
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-	if entry, ok := rl.clients[key]; ok && now.Before(entry.expires) {
-		if entry.count >= rl.limit {
-			return false, time.Until(entry.expires)
-		}
-		entry.count++
-		return true, 0
+func (s *Server) detectCountry(ip string) string {
+	if ip == "" {
+		return "Unknown"
 	}
-
-	rl.clients[key] = &clientWindow{
-		count:   1,
-		expires: now.Add(rl.window),
+	ctx, cancel := context.WithTimeout(context.Background(), geoLookupTimeout)
+	defer cancel()
+	if loc, err := s.geo.Lookup(ctx, ip); err == nil && loc.Country != "" {
+		return loc.Country
 	}
-	return true, 0
+	return "Unknown"
 }
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"link-shortener/internal/lru"
+)
+
+const (
+	rateLimitMaxClients    = 100000
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimitPolicy allows Requests per Period on average, with bursts of up to
+// Burst requests. A policy with zero Requests disables limiting.
+type RateLimitPolicy struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+type RateLimits struct {
+	Shorten   RateLimitPolicy
+	Analytics RateLimitPolicy
+	Redirect  RateLimitPolicy
+}
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Shorten:   RateLimitPolicy{Requests: 10, Period: time.Minute, Burst: 10},
+		Analytics: RateLimitPolicy{Requests: 60, Period: time.Minute, Burst: 20},
+		Redirect:  RateLimitPolicy{Requests: 600, Period: time.Minute, Burst: 100},
+	}
+}
+
+// ParseRateLimitPolicy parses "<requests>/<period>", e.g. "10/1m" or
+// "600/1h". "off" and "0" disable the policy.
+func ParseRateLimitPolicy(raw string) (RateLimitPolicy, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "off" || raw == "0" {
+		return RateLimitPolicy{}, nil
+	}
+	count, period, ok := strings.Cut(raw, "/")
+	if !ok {
+		return RateLimitPolicy{}, fmt.Errorf("rate limit %q must look like 10/1m", raw)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || requests < 0 {
+		return RateLimitPolicy{}, fmt.Errorf("invalid request count in rate limit %q", raw)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("invalid period in rate limit %q", raw)
+	}
+	return RateLimitPolicy{Requests: requests, Period: d, Burst: requests}, nil
+}
+
+func (p RateLimitPolicy) enabled() bool {
+	return p.Requests > 0 && p.Period > 0
+}
+
+func (p RateLimitPolicy) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Requests
+}
+
+func (p RateLimitPolicy) String() string {
+	return fmt.Sprintf("maximum %d requests per %s", p.Requests, describePeriod(p.Period))
+}
+
+func describePeriod(d time.Duration) string {
+	switch d {
+	case time.Second:
+		return "second"
+	case time.Minute:
+		return "minute"
+	case time.Hour:
+		return "hour"
+	case 24 * time.Hour:
+		return "day"
+	}
+	return d.String()
+}
+
+func (s *Server) rateLimit(limiter *rateLimiter, next http.HandlerFunc) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := limiter.Allow(s.clientIP(r))
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", limiter.policyHeader())
+		h.Set("RateLimit-Limit", strconv.Itoa(limiter.capacity))
+		h.Set("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.reset)))
+
+		if !decision.allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(decision.retryAfter)))
+			http.Error(w, "rate limit exceeded: "+limiter.policy.String(), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// rateLimiter is a per-client token bucket. Each bucket holds up to capacity
+// tokens and refills at policy.Requests per policy.Period. A bucket that has
+// refilled completely is indistinguishable from a new client, so it expires
+// at that moment and is swept from memory; the client set is also capped at
+// rateLimitMaxClients, evicting the least recently seen.
+type rateLimiter struct {
+	policy   RateLimitPolicy
+	capacity int
+	rate     float64
+
+	mu      sync.Mutex
+	clients *lru.Cache[string, bucket]
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type rateLimitDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func newRateLimiter(policy RateLimitPolicy) *rateLimiter {
+	if !policy.enabled() {
+		return nil
+	}
+	rl := &rateLimiter{
+		policy:   policy,
+		capacity: policy.burst(),
+		rate:     float64(policy.Requests) / policy.Period.Seconds(),
+		clients:  lru.New[string, bucket](rateLimitMaxClients),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go rl.sweep(rateLimitSweepInterval)
+	return rl
+}
+
+func (rl *rateLimiter) sweep(interval time.Duration) {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case now := <-ticker.C:
+			rl.prune(now)
+		}
+	}
+}
+
+// prune drops the buckets that have refilled by now.
+func (rl *rateLimiter) prune(now time.Time) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.clients.Prune(now)
+}
+
+// Close stops the background sweeper.
+func (rl *rateLimiter) Close() {
+	if rl == nil {
+		return
+	}
+	close(rl.stop)
+	<-rl.done
+}
+
+func (rl *rateLimiter) size() int {
+	if rl == nil {
+		return 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.clients.Len()
+}
+
+func (rl *rateLimiter) policyHeader() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", rl.policy.Requests, ceilSeconds(rl.policy.Period), rl.capacity)
+}
+
+func (rl *rateLimiter) Allow(key string) rateLimitDecision {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	b, ok := rl.clients.Get(key, now)
+	if !ok {
+		b = bucket{tokens: float64(rl.capacity), updated: now}
+	}
+	b.tokens = math.Min(float64(rl.capacity), b.tokens+now.Sub(b.updated).Seconds()*rl.rate)
+	b.updated = now
+
+	decision := rateLimitDecision{allowed: b.tokens >= 1}
+	if decision.allowed {
+		b.tokens--
+		decision.remaining = int(b.tokens)
+	} else {
+		decision.retryAfter = rl.secondsFor(1 - b.tokens)
+	}
+	decision.reset = rl.untilFull(b.tokens)
+	rl.clients.Add(key, b, now.Add(decision.reset))
+	return decision
+}
+
+func (rl *rateLimiter) untilFull(tokens float64) time.Duration {
+	return rl.secondsFor(float64(rl.capacity) - tokens)
+}
+
+func (rl *rateLimiter) secondsFor(tokens float64) time.Duration {
+	return time.Duration(tokens / rl.rate * float64(time.Second))
+}
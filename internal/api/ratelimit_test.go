@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCapsClients(t *testing.T) {
+	rl := newRateLimiter(RateLimitPolicy{Requests: 1, Period: time.Hour, Burst: 1})
+	defer rl.Close()
+
+	for i := 0; i < rateLimitMaxClients+1000; i++ {
+		rl.Allow(fmt.Sprintf("client-%d", i))
+	}
+	if got := rl.size(); got != rateLimitMaxClients {
+		t.Fatalf("size = %d, want %d", got, rateLimitMaxClients)
+	}
+	// The least recently seen clients were evicted and start over.
+	if !rl.Allow("client-0").allowed {
+		t.Fatal("evicted client is still limited")
+	}
+	if rl.Allow(fmt.Sprintf("client-%d", rateLimitMaxClients+999)).allowed {
+		t.Fatal("recent client lost its bucket")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	rl := newRateLimiter(RateLimitPolicy{Requests: 1, Period: time.Minute, Burst: 2})
+	defer rl.Close()
+
+	now := time.Now()
+	rl.Allow("once")  // one token short: full again in a minute
+	rl.Allow("twice") // two tokens short: full again in two minutes
+	rl.Allow("twice")
+
+	if n := rl.prune(now.Add(30 * time.Second)); n != 0 || rl.size() != 2 {
+		t.Fatalf("pruned %d early, %d left", n, rl.size())
+	}
+	if n := rl.prune(now.Add(61 * time.Second)); n != 1 || rl.size() != 1 {
+		t.Fatalf("pruned %d after one refill, %d left; want 1 and 1", n, rl.size())
+	}
+	if n := rl.prune(now.Add(121 * time.Second)); n != 1 || rl.size() != 0 {
+		t.Fatalf("pruned %d after both refilled, %d left; want 1 and 0", n, rl.size())
+	}
+}
+
+func TestRateLimiterCloseStopsSweeper(t *testing.T) {
+	rl := newRateLimiter(RateLimitPolicy{Requests: 1, Period: time.Second})
+	closed := make(chan struct{})
+	go func() {
+		rl.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+	select {
+	case <-rl.done:
+	default:
+		t.Fatal("sweeper still running after Close")
+	}
+
+	var disabled *rateLimiter
+	disabled.Close()
+}
+
+func TestServerCloseStopsRateLimiters(t *testing.T) {
+	s := NewServer(Config{RateLimits: DefaultRateLimits()})
+	if err := s.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	for name, rl := range map[string]*rateLimiter{
+		"shorten":   s.limits.shorten,
+		"analytics": s.limits.analytics,
+		"redirect":  s.limits.redirect,
+	} {
+		select {
+		case <-rl.done:
+		default:
+			t.Errorf("%s sweeper still running after Server.Close", name)
+		}
+	}
+}
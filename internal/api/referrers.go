@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"link-shortener/internal/model"
+)
+
+const (
+	directReferrer    = "direct"
+	maxReferrerLength = 512
+	topReferrers      = 10
+)
+
+// normalizeReferrer reduces a Referer header to host and path: the scheme,
+// port, query, fragment, a leading "www." and trailing slashes are dropped.
+// Anything but an absolute http(s) URL is direct traffic and yields "".
+func normalizeReferrer(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ""
+	}
+	referrer := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") + strings.TrimRight(u.EscapedPath(), "/")
+	if len(referrer) > maxReferrerLength {
+		referrer = referrer[:maxReferrerLength]
+	}
+	return referrer
+}
+
+// countReferrers ranks the referrers of clicks and their domains, with
+// direct traffic as "direct". limit caps both lists; zero keeps everything.
+func countReferrers(clicks []model.Click, limit int) (referrers, domains []namedCount) {
+	byReferrer := make(map[string]int)
+	byDomain := make(map[string]int)
+	for _, click := range clicks {
+		referrer := click.Referrer
+		if referrer == "" {
+			referrer = directReferrer
+		}
+		domain, _, _ := strings.Cut(referrer, "/")
+		byReferrer[referrer]++
+		byDomain[domain]++
+	}
+	return rankCounts(byReferrer, limit), rankCounts(byDomain, limit)
+}
+
+// rankCounts orders counts by clicks, then name, keeping at most limit
+// entries when limit is positive.
+func rankCounts(counts map[string]int, limit int) []namedCount {
+	ranked := make([]namedCount, 0, len(counts))
+	for name, clicks := range counts {
+		ranked = append(ranked, namedCount{Name: name, Clicks: clicks})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Clicks != ranked[j].Clicks {
+			return ranked[i].Clicks > ranked[j].Clicks
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
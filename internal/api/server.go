@@ -1,44 +1,183 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
 	"time"
 
+	"link-shortener/internal/clicks"
+	"link-shortener/internal/geo"
+	"link-shortener/internal/model"
+	"link-shortener/internal/privacy"
 	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/cache"
+	"link-shortener/internal/useragent"
 )
 
 const (
-	minCodeLength     = 6
-	maxCodeLength     = 8
-	rateLimitRequests = 10
-	rateLimitWindow   = time.Minute
+	minCodeLength    = 6
+	maxCodeLength    = 8
+	geoLookupTimeout = 3 * time.Second
+	defaultListLimit = 50
+	maxListLimit     = 200
 )
 
 type Config struct {
 	Store   storage.Store
 	BaseURL string
+
+	// APIKeys enables API key authentication on /api/* when set, and Users
+	// then scopes links to their owners. The redirect handler is always
+	// public.
+	APIKeys storage.APIKeyStore
+	Users   storage.UserStore
+
+	// Workspaces own custom short domains. Redirects for a registered domain
+	// resolve codes in that domain; every other host serves the default
+	// domain, whose short URLs are built from BaseURL.
+	Workspaces storage.WorkspaceStore
+
+	// Geo resolves visitor IPs to locations and is expected to do its own
+	// caching (see geo.Cache). Defaults to a cached lookup against the public
+	// HTTP endpoint in geo.DefaultEndpoint.
+	Geo geo.Locator
+
+	// TrustedProxies lists the peers whose Forwarded/X-Forwarded-For headers
+	// are believed. Requests from anyone else are attributed to RemoteAddr.
+	TrustedProxies []netip.Prefix
+	// TrustXRealIP prefers X-Real-IP over the forwarding chain when the peer
+	// is a trusted proxy.
+	TrustXRealIP bool
+
+	// BotNetworks are address ranges whose clicks are flagged as bots, such
+	// as uptime monitors and link scanners, on top of the user-agent and
+	// HEAD request checks.
+	BotNetworks []netip.Prefix
+
+	// Anonymizer enables privacy mode: clicks are stored with truncated IPs
+	// and a daily visitor hash instead of the raw address. Nil stores IPs
+	// as they are.
+	Anonymizer *privacy.Anonymizer
+
+	// RateLimits are applied per client IP. Zero-valued policies disable
+	// limiting for that route group; see DefaultRateLimits.
+	RateLimits RateLimits
+
+	// RedirectCacheSize is the maximum number of redirect targets kept in
+	// memory. Zero disables the cache.
+	RedirectCacheSize int
+	RedirectCacheTTL  time.Duration
+
+	// Clicks are recorded asynchronously; zero values fall back to the
+	// defaults of the clicks package.
+	ClickBufferSize    int
+	ClickWorkers       int
+	ClickBatchSize     int
+	ClickFlushInterval time.Duration
+	ClickQueuePolicy   clicks.Policy
 }
 
 type Server struct {
-	store   storage.Store
-	baseURL string
-	limiter *rateLimiter
+	store       storage.Store
+	keys        storage.APIKeyStore
+	users       storage.UserStore
+	workspaces  storage.WorkspaceStore
+	cache       *cache.Store
+	clicks      *clicks.Recorder
+	geo         geo.Locator
+	baseURL     string
+	shortScheme string
+
+	trustedProxies []netip.Prefix
+	trustRealIP    bool
+	botNetworks    []netip.Prefix
+	anonymizer     *privacy.Anonymizer
+
+	limits struct {
+		shorten   *rateLimiter
+		analytics *rateLimiter
+		redirect  *rateLimiter
+	}
 }
 
 func NewServer(cfg Config) *Server {
-	return &Server{
-		store:   cfg.Store,
-		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
-		limiter: newRateLimiter(rateLimitRequests, rateLimitWindow),
+	s := &Server{
+		store:       cfg.Store,
+		keys:        cfg.APIKeys,
+		users:       cfg.Users,
+		workspaces:  cfg.Workspaces,
+		baseURL:     strings.TrimSuffix(cfg.BaseURL, "/"),
+		shortScheme: "https",
+		geo:         cfg.Geo,
+
+		trustedProxies: cfg.TrustedProxies,
+		trustRealIP:    cfg.TrustXRealIP,
+		botNetworks:    cfg.BotNetworks,
+		anonymizer:     cfg.Anonymizer,
+	}
+	if parsed, err := url.Parse(s.baseURL); err == nil && parsed.Scheme != "" {
+		s.shortScheme = parsed.Scheme
+	}
+	s.limits.shorten = newRateLimiter(cfg.RateLimits.Shorten)
+	s.limits.analytics = newRateLimiter(cfg.RateLimits.Analytics)
+	s.limits.redirect = newRateLimiter(cfg.RateLimits.Redirect)
+	if s.geo == nil {
+		s.geo = geo.NewCache(geo.NewHTTPLocator(geo.DefaultEndpoint), geo.CacheConfig{})
+	}
+	if cfg.RedirectCacheSize > 0 && cfg.RedirectCacheTTL > 0 {
+		s.cache = cache.New(cfg.Store, cfg.RedirectCacheSize, cfg.RedirectCacheTTL)
+		s.store = s.cache
+		if s.workspaces != nil {
+			s.workspaces = cache.NewWorkspaces(s.workspaces, cfg.RedirectCacheSize, cfg.RedirectCacheTTL)
+		}
+	}
+	s.clicks = clicks.New(clicks.Config{
+		Store:         s.store,
+		Enrich:        s.enrichClick,
+		BufferSize:    cfg.ClickBufferSize,
+		Workers:       cfg.ClickWorkers,
+		BatchSize:     cfg.ClickBatchSize,
+		FlushInterval: cfg.ClickFlushInterval,
+		Policy:        cfg.ClickQueuePolicy,
+	})
+	return s
+}
+
+// Close stops background work and flushes queued clicks. Call it after the HTTP server has stopped
+// accepting requests.
+func (s *Server) Close(ctx context.Context) error {
+	s.limits.shorten.Close()
+	s.limits.analytics.Close()
+	s.limits.redirect.Close()
+	return s.clicks.Close(ctx)
+}
+
+func (s *Server) enrichClick(click *model.Click) {
+	click.Country = s.detectCountry(click.IP)
+	agent := useragent.Parse(click.UserAgent)
+	click.Browser, click.BrowserVersion, click.OS, click.Device = agent.Browser, agent.BrowserVersion, agent.OS, agent.Device
+	click.IsBot = click.IsBot || isBotAgent(agent) || s.isBotNetwork(click.IP)
+	// Everything above needs the full IP, so it is anonymised last.
+	if s.anonymizer != nil {
+		s.anonymizer.Anonymize(click)
 	}
 }
 
 func (s *Server) Routes() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/shorten", s.handleShorten)
-	mux.HandleFunc("/api/links", s.handleListLinks)
-	mux.HandleFunc("/api/links/", s.handleLinkDetails)
-	mux.HandleFunc("/", s.handleRedirect)
-	return s.rateLimitMiddleware(jsonMiddleware(mux))
+	mux.Handle("/api/shorten", s.rateLimit(s.limits.shorten, s.requireAPIKey(s.handleShorten)))
+	mux.Handle("/api/links", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleListLinks)))
+	mux.Handle("/api/links/", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleLink)))
+	mux.Handle("/api/keys", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleAPIKeys)))
+	mux.Handle("/api/keys/", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleAPIKey)))
+	mux.Handle("/api/users", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleUsers)))
+	mux.Handle("/api/workspaces", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleWorkspaces)))
+	mux.Handle("/api/workspaces/", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleWorkspaceDomains)))
+	mux.Handle("/api/metrics", s.rateLimit(s.limits.analytics, s.requireAPIKey(s.handleMetrics)))
+	mux.Handle("/api/", s.rateLimit(s.limits.analytics, s.requireAPIKey(http.NotFound)))
+	mux.Handle("/", s.rateLimit(s.limits.redirect, s.handleRedirect))
+	return jsonMiddleware(mux)
 }
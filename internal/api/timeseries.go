@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"link-shortener/internal/storage"
+)
+
+const maxTimeseriesBuckets = 1000
+
+// defaultTimeseriesSpans is how far back a timeseries reaches when the
+// request gives no from.
+var defaultTimeseriesSpans = map[string]time.Duration{
+	"minute": time.Hour,
+	"hour":   24 * time.Hour,
+	"day":    30 * 24 * time.Hour,
+	"week":   12 * 7 * 24 * time.Hour,
+}
+
+type timeseriesQuery struct {
+	granularity string
+	location    *time.Location
+	from        time.Time
+	to          time.Time
+	includeBots bool
+}
+
+func parseTimeseriesQuery(query url.Values, now time.Time) (timeseriesQuery, error) {
+	q := timeseriesQuery{granularity: "hour", location: time.UTC, to: now}
+
+	if raw := strings.TrimSpace(query.Get("granularity")); raw != "" {
+		if _, ok := defaultTimeseriesSpans[raw]; !ok {
+			return q, errors.New("granularity must be one of minute, hour, day, week")
+		}
+		q.granularity = raw
+	}
+	if raw := strings.TrimSpace(query.Get("tz")); raw != "" {
+		loc, err := time.LoadLocation(raw)
+		if err != nil {
+			return q, fmt.Errorf("unknown time zone %q", raw)
+		}
+		q.location = loc
+	}
+	if raw := strings.TrimSpace(query.Get("to")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return q, errors.New("to must be RFC3339 timestamp")
+		}
+		q.to = t
+	}
+	q.from = q.to.Add(-defaultTimeseriesSpans[q.granularity])
+	if raw := strings.TrimSpace(query.Get("from")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return q, errors.New("from must be RFC3339 timestamp")
+		}
+		q.from = t
+	}
+	if !q.from.Before(q.to) {
+		return q, errors.New("from must be before to")
+	}
+	includeBots, err := parseIncludeBots(query)
+	if err != nil {
+		return q, err
+	}
+	q.includeBots = includeBots
+	return q, nil
+}
+
+// buckets splits [from, to) into consecutive buckets aligned to the
+// granularity in the requested time zone, so days start at local midnight
+// and weeks on Monday. The first bucket starts at or before from.
+func (q timeseriesQuery) buckets() ([]storage.TimeBucket, error) {
+	var buckets []storage.TimeBucket
+	for start := q.truncate(q.from.In(q.location)); start.Before(q.to); {
+		if len(buckets) == maxTimeseriesBuckets {
+			return nil, fmt.Errorf("range spans more than %d %s buckets", maxTimeseriesBuckets, q.granularity)
+		}
+		end := q.next(start)
+		buckets = append(buckets, storage.TimeBucket{Start: start, End: end})
+		start = end
+	}
+	return buckets, nil
+}
+
+func (q timeseriesQuery) truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch q.granularity {
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, q.location)
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, q.location)
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, q.location)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, q.location)
+	}
+}
+
+// next steps minutes and hours in absolute time and days and weeks on the
+// calendar, so a bucket spanning a DST change is 23 or 25 hours long.
+func (q timeseriesQuery) next(start time.Time) time.Time {
+	y, m, d := start.Date()
+	switch q.granularity {
+	case "minute":
+		return start.Add(time.Minute)
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return time.Date(y, m, d+7, 0, 0, 0, 0, q.location)
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, q.location)
+	}
+}
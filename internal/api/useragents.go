@@ -0,0 +1,35 @@
+package api
+
+import (
+	"link-shortener/internal/model"
+	"link-shortener/internal/useragent"
+)
+
+// clickAgent returns the parsed user agent of a click. Clicks recorded before
+// user agents were parsed on the way in are parsed on the fly.
+func clickAgent(click model.Click) useragent.Agent {
+	if click.Device == "" {
+		return useragent.Parse(click.UserAgent)
+	}
+	return useragent.Agent{
+		Browser:        click.Browser,
+		BrowserVersion: click.BrowserVersion,
+		OS:             click.OS,
+		Device:         click.Device,
+	}
+}
+
+// countAgents ranks the browser families, operating systems and device
+// classes of clicks.
+func countAgents(clicks []model.Click) (browsers, systems, devices []namedCount) {
+	byBrowser := make(map[string]int)
+	bySystem := make(map[string]int)
+	byDevice := make(map[string]int)
+	for _, click := range clicks {
+		agent := clickAgent(click)
+		byBrowser[agent.Browser]++
+		bySystem[agent.OS]++
+		byDevice[agent.Device]++
+	}
+	return rankCounts(byBrowser, 0), rankCounts(bySystem, 0), rankCounts(byDevice, 0)
+}
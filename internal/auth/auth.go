@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"slices"
+)
+
+const (
+	ScopeLinksWrite    = "links:write"
+	ScopeAnalyticsRead = "analytics:read"
+	// ScopeAdmin grants every other scope and access to key management.
+	ScopeAdmin = "admin"
+)
+
+const (
+	keyPrefix     = "lsk_"
+	keyLength     = 40
+	keyAlphabet   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	displayLength = len(keyPrefix) + 6
+)
+
+var Scopes = []string{ScopeLinksWrite, ScopeAnalyticsRead, ScopeAdmin}
+
+// GenerateKey returns a new random API key. Only its hash should be stored.
+func GenerateKey() (string, error) {
+	buf := make([]byte, keyLength)
+	max := big.NewInt(int64(len(keyAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = keyAlphabet[n.Int64()]
+	}
+	return keyPrefix + string(buf), nil
+}
+
+// HashKey derives the value stored at rest. Keys are long random strings, so
+// a fast unsalted hash is sufficient and allows lookup by hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// DisplayPrefix is the non-secret beginning of a key shown in listings.
+func DisplayPrefix(key string) string {
+	if len(key) <= displayLength {
+		return key
+	}
+	return key[:displayLength]
+}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+func HasScope(granted []string, want string) bool {
+	return slices.Contains(granted, ScopeAdmin) || slices.Contains(granted, want)
+}
@@ -0,0 +1,235 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+type Policy string
+
+const (
+	// PolicyDrop discards clicks when the buffer is full.
+	PolicyDrop Policy = "drop"
+	// PolicyBlock makes callers wait for buffer space until their context ends.
+	PolicyBlock Policy = "block"
+)
+
+const (
+	defaultBufferSize    = 1024
+	defaultWorkers       = 4
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+var ErrClosed = errors.New("click recorder closed")
+
+type Config struct {
+	Store         storage.Store
+	Enrich        func(click *model.Click)
+	BufferSize    int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	Policy        Policy
+}
+
+type Stats struct {
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	Accepted uint64 `json:"accepted"`
+	Dropped  uint64 `json:"dropped"`
+	Written  uint64 `json:"written"`
+	Failed   uint64 `json:"failed"`
+}
+
+// Recorder buffers clicks in memory, enriches them on background workers and
+// writes them to the store in batches, so redirects never wait on enrichment
+// or I/O.
+type Recorder struct {
+	store         storage.Store
+	enrich        func(click *model.Click)
+	batchSize     int
+	flushInterval time.Duration
+	policy        Policy
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan storage.ClickEvent
+	done   chan struct{}
+
+	accepted atomic.Uint64
+	dropped  atomic.Uint64
+	written  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func New(cfg Config) *Recorder {
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	policy := cfg.Policy
+	if policy == "" {
+		policy = PolicyDrop
+	}
+
+	r := &Recorder{
+		store:         cfg.Store,
+		enrich:        cfg.Enrich,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		policy:        policy,
+		queue:         make(chan storage.ClickEvent, bufferSize),
+		done:          make(chan struct{}),
+	}
+
+	enriched := make(chan storage.ClickEvent, batchSize)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.enrichLoop(enriched)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(enriched)
+	}()
+	go func() {
+		defer close(r.done)
+		r.writeLoop(enriched)
+	}()
+	return r
+}
+
+// Record queues a click. It reports false when the click was dropped because
+// the buffer was full, the caller's context ended or the recorder is closed.
+func (r *Recorder) Record(ctx context.Context, domain, code string, click model.Click) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		r.dropped.Add(1)
+		return false
+	}
+
+	event := storage.ClickEvent{Domain: domain, Code: code, Click: click}
+	if r.policy == PolicyBlock {
+		select {
+		case r.queue <- event:
+			r.accepted.Add(1)
+			return true
+		case <-ctx.Done():
+			r.dropped.Add(1)
+			return false
+		}
+	}
+
+	select {
+	case r.queue <- event:
+		r.accepted.Add(1)
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Close stops accepting clicks and waits for the queued ones to be written.
+func (r *Recorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrClosed
+	}
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Recorder) Stats() Stats {
+	return Stats{
+		Queued:   len(r.queue),
+		Capacity: cap(r.queue),
+		Accepted: r.accepted.Load(),
+		Dropped:  r.dropped.Load(),
+		Written:  r.written.Load(),
+		Failed:   r.failed.Load(),
+	}
+}
+
+func (r *Recorder) enrichLoop(out chan<- storage.ClickEvent) {
+	for event := range r.queue {
+		if r.enrich != nil {
+			r.enrich(&event.Click)
+		}
+		out <- event
+	}
+}
+
+// writeLoop is the single writer, so batches never contend with each other
+// for the database.
+func (r *Recorder) writeLoop(in <-chan storage.ClickEvent) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.ClickEvent, 0, r.batchSize)
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []storage.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	// Batches outlive the requests that queued them, so they are written
+	// without a request context.
+	if err := r.store.RecordClicks(context.Background(), batch); err != nil {
+		r.failed.Add(uint64(len(batch)))
+		log.Printf("failed to record %d clicks: %v", len(batch), err)
+		return
+	}
+	r.written.Add(uint64(len(batch)))
+}
@@ -0,0 +1,148 @@
+package geo
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"link-shortener/internal/lru"
+)
+
+const (
+	defaultCacheSize = 10000
+	defaultCacheTTL  = 24 * time.Hour
+)
+
+// Persister stores resolved locations keyed by network prefix so they
+// survive restarts.
+type Persister interface {
+	LoadLocation(ctx context.Context, prefix string, now time.Time) (Location, bool, error)
+	SaveLocation(ctx context.Context, prefix string, loc Location, expires time.Time) error
+}
+
+type CacheConfig struct {
+	Size      int
+	TTL       time.Duration
+	Persister Persister
+}
+
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Persisted uint64 `json:"persistedHits"`
+	Entries   int    `json:"entries"`
+	Capacity  int    `json:"capacity"`
+}
+
+// Cache wraps a Locator with a bounded LRU and an optional persistent layer.
+// Entries are keyed by the visitor's /24 (IPv4) or /48 (IPv6) network.
+type Cache struct {
+	next      Locator
+	ttl       time.Duration
+	persister Persister
+
+	mu      sync.Mutex
+	entries *lru.Cache[string, Location]
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	persisted atomic.Uint64
+}
+
+func NewCache(next Locator, cfg CacheConfig) *Cache {
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &Cache{
+		next:      next,
+		ttl:       ttl,
+		persister: cfg.Persister,
+		entries:   lru.New[string, Location](size),
+	}
+}
+
+func (c *Cache) Lookup(ctx context.Context, ip string) (Location, error) {
+	prefix, ok := Prefix(ip)
+	if !ok {
+		return c.next.Lookup(ctx, ip)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	loc, ok := c.entries.Get(prefix, now)
+	c.mu.Unlock()
+	if ok {
+		c.hits.Add(1)
+		return loc, nil
+	}
+	c.misses.Add(1)
+
+	if c.persister != nil {
+		loc, ok, err := c.persister.LoadLocation(ctx, prefix, now)
+		if err != nil {
+			log.Printf("failed to load cached location for %s: %v", prefix, err)
+		} else if ok {
+			c.persisted.Add(1)
+			c.remember(prefix, loc, now.Add(c.ttl))
+			return loc, nil
+		}
+	}
+
+	loc, err := c.next.Lookup(ctx, ip)
+	if err != nil {
+		return Location{}, err
+	}
+	expires := now.Add(c.ttl)
+	c.remember(prefix, loc, expires)
+	if c.persister != nil {
+		if err := c.persister.SaveLocation(ctx, prefix, loc, expires); err != nil {
+			log.Printf("failed to persist location for %s: %v", prefix, err)
+		}
+	}
+	return loc, nil
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Persisted: c.persisted.Load(),
+		Entries:   c.entries.Len(),
+		Capacity:  c.entries.Cap(),
+	}
+}
+
+func (c *Cache) remember(prefix string, loc Location, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Add(prefix, loc, expires)
+}
+
+// Prefix returns the network an IP is cached under: /24 for IPv4 and /48 for
+// IPv6.
+func Prefix(ip string) (string, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", false
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "", false
+	}
+	return prefix.String(), true
+}
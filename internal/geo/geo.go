@@ -0,0 +1,22 @@
+package geo
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("location not found")
+
+type Location struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
+
+// Locator resolves an IP address to a location. Implementations return
+// ErrNotFound when the address is unknown to them.
+type Locator interface {
+	Lookup(ctx context.Context, ip string) (Location, error)
+}
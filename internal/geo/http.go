@@ -0,0 +1,72 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultEndpoint = "https://ipapi.co/%s/country/"
+
+// HTTPLocator asks a remote endpoint for the country of an IP. The endpoint
+// either contains a %s placeholder for the IP or has the IP appended as the
+// last path segment, and must answer with the bare country code.
+type HTTPLocator struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewHTTPLocator(endpoint string) *HTTPLocator {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = DefaultEndpoint
+	}
+	return &HTTPLocator{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (l *HTTPLocator) Lookup(ctx context.Context, ip string) (Location, error) {
+	var lookupURL string
+	if strings.Contains(l.endpoint, "%s") {
+		lookupURL = fmt.Sprintf(l.endpoint, url.PathEscape(ip))
+	} else {
+		lookupURL = fmt.Sprintf("%s/%s", strings.TrimSuffix(l.endpoint, "/"), url.PathEscape(ip))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return Location{}, err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return Location{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Location{}, ErrNotFound
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return Location{}, fmt.Errorf("geo lookup failed: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Location{}, err
+	}
+	country := strings.TrimSpace(string(body))
+	if country == "" {
+		return Location{}, errors.New("empty geo response")
+	}
+	return Location{Country: country}, nil
+}
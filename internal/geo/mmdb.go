@@ -0,0 +1,197 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// mmdbRecord covers the fields shared by MaxMind GeoLite2/GeoIP2 City,
+// Country and ASN databases and the DB-IP equivalents.
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+type mmdbFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	reader  *maxminddb.Reader
+}
+
+// MMDBLocator answers lookups from local .mmdb files. When several files are
+// given (for example a City and an ASN database) their results are merged.
+// Files are re-opened when their modification time or size changes.
+type MMDBLocator struct {
+	mu    sync.RWMutex
+	files []*mmdbFile
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewMMDBLocator(reloadInterval time.Duration, paths ...string) (*MMDBLocator, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one mmdb path is required")
+	}
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+
+	l := &MMDBLocator{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for _, path := range paths {
+		file, err := openMMDB(path)
+		if err != nil {
+			l.closeReaders()
+			return nil, err
+		}
+		l.files = append(l.files, file)
+	}
+
+	go l.watch(reloadInterval)
+	return l, nil
+}
+
+func (l *MMDBLocator) Lookup(_ context.Context, ip string) (Location, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}, fmt.Errorf("invalid ip %q", ip)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var loc Location
+	found := false
+	for _, file := range l.files {
+		var record mmdbRecord
+		_, ok, err := file.reader.LookupNetwork(addr, &record)
+		if err != nil {
+			return Location{}, err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		mergeRecord(&loc, record)
+	}
+	if !found {
+		return Location{}, ErrNotFound
+	}
+	return loc, nil
+}
+
+// Close stops watching for changes and releases the databases.
+func (l *MMDBLocator) Close() error {
+	close(l.stop)
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeReaders()
+}
+
+func (l *MMDBLocator) watch(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.reloadChanged()
+		}
+	}
+}
+
+func (l *MMDBLocator) reloadChanged() {
+	for i, file := range l.files {
+		info, err := os.Stat(file.path)
+		if err != nil || (info.ModTime().Equal(file.modTime) && info.Size() == file.size) {
+			continue
+		}
+		next, err := openMMDB(file.path)
+		if err != nil {
+			// The file may still be mid-copy; try again on the next tick.
+			log.Printf("failed to reload geo database %s: %v", file.path, err)
+			continue
+		}
+
+		l.mu.Lock()
+		l.files[i] = next
+		l.mu.Unlock()
+
+		file.reader.Close()
+		log.Printf("reloaded geo database %s", file.path)
+	}
+}
+
+func (l *MMDBLocator) closeReaders() error {
+	var errs []error
+	for _, file := range l.files {
+		errs = append(errs, file.reader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func openMMDB(path string) (*mmdbFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &mmdbFile{
+		path:    path,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		reader:  reader,
+	}, nil
+}
+
+func mergeRecord(loc *Location, record mmdbRecord) {
+	if loc.Country == "" {
+		loc.Country = record.Country.ISOCode
+	}
+	if loc.Region == "" && len(record.Subdivisions) > 0 {
+		region := record.Subdivisions[0]
+		loc.Region = region.Names["en"]
+		if loc.Region == "" {
+			loc.Region = region.ISOCode
+		}
+	}
+	if loc.City == "" {
+		loc.City = record.City.Names["en"]
+	}
+	if loc.ASN == 0 {
+		loc.ASN = record.ASN
+		loc.ASOrg = record.ASOrg
+	}
+}
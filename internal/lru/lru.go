@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"container/list"
+	"time"
+)
+
+// Cache is a size-bounded least-recently-used map whose entries also expire
+// at a fixed time. It is not safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	size    int
+	order   *list.List
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func New[K comparable, V any](size int) *Cache[K, V] {
+	return &Cache[K, V]{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+func (c *Cache[K, V]) Get(key K, now time.Time) (V, bool) {
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := elem.Value.(*entry[K, V])
+	if !now.Before(e.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+func (c *Cache[K, V]) Add(key K, value V, expires time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = &entry[K, V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[K, V]).key)
+	}
+}
+
+func (c *Cache[K, V]) Remove(key K) {
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// Prune drops every entry that has expired by now and reports how many were
+// removed.
+func (c *Cache[K, V]) Prune(now time.Time) int {
+	removed := 0
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		e := elem.Value.(*entry[K, V])
+		if !now.Before(e.expires) {
+			c.order.Remove(elem)
+			delete(c.entries, e.key)
+			removed++
+		}
+		elem = prev
+	}
+	return removed
+}
+
+func (c *Cache[K, V]) Len() int {
+	return c.order.Len()
+}
+
+func (c *Cache[K, V]) Cap() int {
+	return c.size
+}
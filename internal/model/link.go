@@ -3,12 +3,35 @@ package model
 import "time"
 
 type Link struct {
-	Code        string              `json:"code"`
-	OriginalURL string              `json:"originalUrl"`
-	CreatedAt   time.Time           `json:"createdAt"`
-	ExpiresAt   time.Time           `json:"expiresAt"`
-	Clicks      []Click             `json:"-"`
-	UniqueIPs   map[string]struct{} `json:"-"`
+	Domain      string    `json:"domain,omitempty"`
+	Code        string    `json:"code"`
+	OwnerID     int64     `json:"ownerId,omitempty"`
+	OriginalURL string    `json:"originalUrl"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Clicks      []Click   `json:"-"`
+	// UniqueIPs holds the visitor key (see Click.VisitorKey) of every human
+	// that clicked the link.
+	UniqueIPs map[string]struct{} `json:"-"`
+}
+
+type LinkTarget struct {
+	Domain      string    `json:"domain,omitempty"`
+	Code        string    `json:"code"`
+	OwnerID     int64     `json:"ownerId,omitempty"`
+	OriginalURL string    `json:"originalUrl"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+type LinkSummary struct {
+	Domain         string    `json:"domain,omitempty"`
+	Code           string    `json:"code"`
+	OwnerID        int64     `json:"ownerId,omitempty"`
+	OriginalURL    string    `json:"originalUrl"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	TotalClicks    int       `json:"totalClicks"`
+	UniqueVisitors int       `json:"uniqueVisitors"`
 }
 
 type Click struct {
@@ -16,4 +39,42 @@ type Click struct {
 	IP        string    `json:"ip"`
 	Country   string    `json:"country"`
 	UserAgent string    `json:"userAgent"`
+	// Referrer is the normalised host and path of the Referer header, empty
+	// for direct traffic.
+	Referrer string `json:"referrer"`
+	// Browser, BrowserVersion, OS and Device are parsed from UserAgent when
+	// the click is recorded.
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browserVersion"`
+	OS             string `json:"os"`
+	Device         string `json:"device"`
+	// IsBot marks clicks from crawlers, link previews and monitoring probes.
+	IsBot bool `json:"isBot"`
+	// Visitor identifies the visitor for unique counts. In privacy mode it is
+	// a daily hash and IP is truncated; otherwise it is empty or the IP.
+	Visitor string `json:"visitor"`
+}
+
+// VisitorKey is what makes a click's visitor unique: Visitor, falling back
+// to the IP.
+func (c Click) VisitorKey() string {
+	if c.Visitor != "" {
+		return c.Visitor
+	}
+	return c.IP
+}
+
+// LinkGeneration is a link that held a code until the code expired and was
+// claimed again. It is archived with its clicks under ID.
+type LinkGeneration struct {
+	ID         int64     `json:"id"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	Link
+}
+
+// GenerationSummary is a LinkGeneration with counts instead of clicks.
+type GenerationSummary struct {
+	ID         int64     `json:"id"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	LinkSummary
 }
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+type User struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	WorkspaceID int64     `json:"workspaceId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (u *User) IsAdmin() bool {
+	return u != nil && u.Role == RoleAdmin
+}
+
+type APIKey struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId,omitempty"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Workspace groups users under one or more short domains. Links created on a
+// workspace domain get codes that are unique only within that domain.
+type Workspace struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Domains   []string  `json:"domains"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PrimaryDomain is the domain new links are created on when the caller does
+// not pick one.
+func (w *Workspace) PrimaryDomain() string {
+	if w == nil || len(w.Domains) == 0 {
+		return ""
+	}
+	return w.Domains[0]
+}
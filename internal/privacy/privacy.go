@@ -0,0 +1,68 @@
+// Package privacy anonymises clicks before they are stored. IPs are cut down
+// to their /24 (IPv4) or /48 (IPv6) network, and unique visitors are told
+// apart by a keyed hash of the full IP that changes every UTC day, so a
+// visitor cannot be followed from one day to the next and the hash cannot be
+// reversed without the secret.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"time"
+
+	"link-shortener/internal/model"
+)
+
+// visitorLength is the number of hex characters kept from a visitor hash.
+const visitorLength = 32
+
+type Anonymizer struct {
+	secret []byte
+}
+
+// New returns an Anonymizer keyed with secret. Instances sharing a database
+// must share the secret for their unique visitors to match.
+func New(secret []byte) *Anonymizer {
+	return &Anonymizer{secret: secret}
+}
+
+// Anonymize replaces the click's visitor with the hash of its IP for the day
+// of the click and truncates the IP. Clicks without an IP are left alone.
+func (a *Anonymizer) Anonymize(click *model.Click) {
+	if click.IP == "" {
+		return
+	}
+	click.Visitor = a.Visitor(click.IP, click.Timestamp)
+	click.IP = TruncateIP(click.IP)
+}
+
+// Visitor hashes ip with a salt derived from the secret and the UTC day of at.
+func (a *Anonymizer) Visitor(ip string, at time.Time) string {
+	salt := hmac.New(sha256.New, a.secret)
+	salt.Write([]byte(at.UTC().Format(time.DateOnly)))
+	mac := hmac.New(sha256.New, salt.Sum(nil))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))[:visitorLength]
+}
+
+// TruncateIP zeroes the host part of ip beyond its /24 (IPv4) or /48 (IPv6)
+// network, the granularity the geo cache already uses. Anything that is not
+// an IP is dropped.
+func TruncateIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
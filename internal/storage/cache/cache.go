@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"link-shortener/internal/lru"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// Store decorates a storage.Store with a bounded LRU cache of redirect
+// targets. Every write that can change a code's destination or expiry
+// invalidates the affected entries.
+type Store struct {
+	inner storage.Store
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries *lru.Cache[string, model.LinkTarget]
+	epoch   uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type Stats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+}
+
+func New(inner storage.Store, size int, ttl time.Duration) *Store {
+	return &Store{
+		inner:   inner,
+		ttl:     ttl,
+		entries: lru.New[string, model.LinkTarget](size),
+	}
+}
+
+func (s *Store) Resolve(ctx context.Context, domain, code string) (*model.LinkTarget, error) {
+	key := cacheKey(domain, code)
+	now := time.Now()
+	if target, ok := s.lookup(key, now); ok {
+		s.hits.Add(1)
+		return target, nil
+	}
+	s.misses.Add(1)
+
+	s.mu.Lock()
+	epoch := s.epoch
+	s.mu.Unlock()
+
+	target, err := s.inner.Resolve(ctx, domain, code)
+	if err != nil {
+		return nil, err
+	}
+	s.store(key, *target, epoch, now)
+	return target, nil
+}
+
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Hits:     s.hits.Load(),
+		Misses:   s.misses.Load(),
+		Entries:  s.entries.Len(),
+		Capacity: s.entries.Cap(),
+	}
+}
+
+func (s *Store) Save(ctx context.Context, link *model.Link) error {
+	defer s.invalidate(link.Domain, link.Code)
+	return s.inner.Save(ctx, link)
+}
+
+func (s *Store) ReplaceExpired(ctx context.Context, link *model.Link) error {
+	defer s.invalidate(link.Domain, link.Code)
+	return s.inner.ReplaceExpired(ctx, link)
+}
+
+func (s *Store) Update(ctx context.Context, domain, code string, update storage.LinkUpdate) (*model.Link, error) {
+	if update.Code != nil {
+		defer s.invalidate(domain, *update.Code)
+	}
+	defer s.invalidate(domain, code)
+	return s.inner.Update(ctx, domain, code, update)
+}
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	defer s.invalidate(domain, code)
+	return s.inner.Delete(ctx, domain, code)
+}
+
+func (s *Store) Get(ctx context.Context, domain, code string) (*model.Link, error) {
+	return s.inner.Get(ctx, domain, code)
+}
+
+func (s *Store) ClickTimeseries(ctx context.Context, domain, code string, buckets []storage.TimeBucket, includeBots bool) ([]int, error) {
+	return s.inner.ClickTimeseries(ctx, domain, code, buckets, includeBots)
+}
+
+func (s *Store) ListGenerations(ctx context.Context, domain, code string, includeBots bool) ([]model.GenerationSummary, error) {
+	return s.inner.ListGenerations(ctx, domain, code, includeBots)
+}
+
+func (s *Store) Generation(ctx context.Context, domain, code string, id int64) (*model.LinkGeneration, error) {
+	return s.inner.Generation(ctx, domain, code, id)
+}
+
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) (storage.LinkPage, error) {
+	return s.inner.List(ctx, opts)
+}
+
+func (s *Store) RecordClick(ctx context.Context, domain, code string, click model.Click) error {
+	return s.inner.RecordClick(ctx, domain, code, click)
+}
+
+func (s *Store) RecordClicks(ctx context.Context, events []storage.ClickEvent) error {
+	return s.inner.RecordClicks(ctx, events)
+}
+
+// cacheKey joins domain and code with a slash, which neither may contain.
+func cacheKey(domain, code string) string {
+	return domain + "/" + code
+}
+
+func (s *Store) lookup(key string, now time.Time) (*model.LinkTarget, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, ok := s.entries.Get(key, now)
+	if !ok {
+		return nil, false
+	}
+	return &target, true
+}
+
+func (s *Store) store(key string, target model.LinkTarget, epoch uint64, now time.Time) {
+	expires := now.Add(s.ttl)
+	if target.ExpiresAt.Before(expires) {
+		expires = target.ExpiresAt
+	}
+	if !now.Before(expires) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A write landed while we were reading from the inner store, so the
+	// target may already be stale.
+	if epoch != s.epoch {
+		return
+	}
+	s.entries.Add(key, target, expires)
+}
+
+func (s *Store) invalidate(domain, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epoch++
+	s.entries.Remove(cacheKey(domain, code))
+}
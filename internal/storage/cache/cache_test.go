@@ -0,0 +1,77 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/cache"
+	"link-shortener/internal/storage/memory"
+	"link-shortener/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return cache.New(memory.New(), 100, time.Minute)
+	})
+}
+
+// fakeWorkspaces counts domain lookups against a fixed set of workspaces.
+type fakeWorkspaces struct {
+	storage.WorkspaceStore
+	mu         sync.Mutex
+	workspaces []*model.Workspace
+	lookups    int
+}
+
+func (f *fakeWorkspaces) WorkspaceByDomain(ctx context.Context, domain string) (*model.Workspace, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	for _, w := range f.workspaces {
+		if slices.Contains(w.Domains, domain) {
+			return w, nil
+		}
+	}
+	return nil, storage.ErrWorkspaceNotFound
+}
+
+func (f *fakeWorkspaces) CreateWorkspace(ctx context.Context, workspace *model.Workspace) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	workspace.ID = int64(len(f.workspaces) + 1)
+	f.workspaces = append(f.workspaces, workspace)
+	return nil
+}
+
+func TestWorkspacesCachesDomainLookups(t *testing.T) {
+	inner := &fakeWorkspaces{}
+	w := cache.NewWorkspaces(inner, 100, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.WorkspaceByDomain(t.Context(), "go.example.com"); !errors.Is(err, storage.ErrWorkspaceNotFound) {
+			t.Fatalf("unregistered domain: %v, want ErrWorkspaceNotFound", err)
+		}
+	}
+	if inner.lookups != 1 {
+		t.Fatalf("inner lookups = %d, want 1", inner.lookups)
+	}
+
+	if err := w.CreateWorkspace(t.Context(), &model.Workspace{Name: "Go", Domains: []string{"go.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		got, err := w.WorkspaceByDomain(t.Context(), "go.example.com")
+		if err != nil || got.Name != "Go" {
+			t.Fatalf("registered domain = %+v, %v", got, err)
+		}
+	}
+	if inner.lookups != 2 {
+		t.Fatalf("inner lookups = %d, want 2", inner.lookups)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"link-shortener/internal/lru"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// Workspaces decorates a storage.WorkspaceStore with a bounded LRU of
+// WorkspaceByDomain answers, which every redirect asks for its Host. Hosts
+// that no workspace registered are cached too, since that is the common
+// case. Creating a workspace or adding a domain empties the cache; domains
+// are never removed, so the TTL only bounds how long other instances' writes
+// go unseen.
+type Workspaces struct {
+	inner storage.WorkspaceStore
+	size  int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries *lru.Cache[string, *model.Workspace] // nil: not registered
+	epoch   uint64
+}
+
+func NewWorkspaces(inner storage.WorkspaceStore, size int, ttl time.Duration) *Workspaces {
+	return &Workspaces{
+		inner:   inner,
+		size:    size,
+		ttl:     ttl,
+		entries: lru.New[string, *model.Workspace](size),
+	}
+}
+
+func (w *Workspaces) WorkspaceByDomain(ctx context.Context, domain string) (*model.Workspace, error) {
+	now := time.Now()
+	w.mu.Lock()
+	cached, ok := w.entries.Get(domain, now)
+	epoch := w.epoch
+	w.mu.Unlock()
+	if ok {
+		if cached == nil {
+			return nil, storage.ErrWorkspaceNotFound
+		}
+		return cloneWorkspace(cached), nil
+	}
+
+	workspace, err := w.inner.WorkspaceByDomain(ctx, domain)
+	switch {
+	case errors.Is(err, storage.ErrWorkspaceNotFound):
+		w.store(domain, nil, epoch, now)
+	case err != nil:
+		return nil, err
+	default:
+		w.store(domain, cloneWorkspace(workspace), epoch, now)
+	}
+	return workspace, err
+}
+
+func (w *Workspaces) CreateWorkspace(ctx context.Context, workspace *model.Workspace) error {
+	defer w.purge()
+	return w.inner.CreateWorkspace(ctx, workspace)
+}
+
+func (w *Workspaces) AddDomain(ctx context.Context, workspaceID int64, domain string) error {
+	defer w.purge()
+	return w.inner.AddDomain(ctx, workspaceID, domain)
+}
+
+func (w *Workspaces) WorkspaceByID(ctx context.Context, id int64) (*model.Workspace, error) {
+	return w.inner.WorkspaceByID(ctx, id)
+}
+
+func (w *Workspaces) ListWorkspaces(ctx context.Context) ([]model.Workspace, error) {
+	return w.inner.ListWorkspaces(ctx)
+}
+
+func (w *Workspaces) store(domain string, workspace *model.Workspace, epoch uint64, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// A workspace or domain was added while we were reading, so the answer
+	// may already be stale.
+	if epoch != w.epoch {
+		return
+	}
+	w.entries.Add(domain, workspace, now.Add(w.ttl))
+}
+
+func (w *Workspaces) purge() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.epoch++
+	w.entries = lru.New[string, *model.Workspace](w.size)
+}
+
+func cloneWorkspace(workspace *model.Workspace) *model.Workspace {
+	c := *workspace
+	c.Domains = slices.Clone(workspace.Domains)
+	return &c
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+type listCursor struct {
+	Sort   storage.SortField `json:"s"`
+	Value  string            `json:"v"`
+	Code   string            `json:"c"`
+	Domain string            `json:"d,omitempty"`
+}
+
+// cursor is the last link of the previous page, with only the sort value,
+// code and domain set.
+type cursor struct {
+	link model.LinkSummary
+}
+
+func encodeCursor(sort storage.SortField, link model.LinkSummary) string {
+	c := listCursor{Sort: sort, Code: link.Code, Domain: link.Domain}
+	switch sort {
+	case storage.SortCreated:
+		c.Value = link.CreatedAt.Format(time.RFC3339Nano)
+	case storage.SortExpires:
+		c.Value = link.ExpiresAt.Format(time.RFC3339Nano)
+	case storage.SortClicks:
+		c.Value = strconv.Itoa(link.TotalClicks)
+	case storage.SortUniqueVisitors:
+		c.Value = strconv.Itoa(link.UniqueVisitors)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, sort storage.SortField) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor{}, storage.ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.Code == "" {
+		return cursor{}, storage.ErrInvalidCursor
+	}
+	link := model.LinkSummary{Code: c.Code, Domain: c.Domain}
+	switch sort {
+	case storage.SortClicks, storage.SortUniqueVisitors:
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return cursor{}, storage.ErrInvalidCursor
+		}
+		link.TotalClicks, link.UniqueVisitors = n, n
+	default:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return cursor{}, storage.ErrInvalidCursor
+		}
+		link.CreatedAt, link.ExpiresAt = t, t
+	}
+	return cursor{link: link}, nil
+}
+
+func errUnknownSort(sort storage.SortField) error {
+	return fmt.Errorf("unknown sort field %q", sort)
+}
+
+func errUnknownStatus(status storage.LinkStatus) error {
+	return fmt.Errorf("unknown link status %q", status)
+}
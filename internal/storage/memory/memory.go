@@ -0,0 +1,389 @@
+// Package memory is a storage.Store kept entirely in process memory. It has
+// no dependencies and loses everything on exit, which suits tests and demos.
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+type key struct {
+	domain, code string
+}
+
+type Store struct {
+	mu             sync.RWMutex
+	links          map[key]*model.Link
+	generations    map[key][]*model.LinkGeneration
+	lastGeneration int64
+}
+
+func New() *Store {
+	return &Store{
+		links:       make(map[key]*model.Link),
+		generations: make(map[key][]*model.LinkGeneration),
+	}
+}
+
+func (s *Store) Save(_ context.Context, link *model.Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{link.Domain, link.Code}
+	if _, exists := s.links[k]; exists {
+		return storage.ErrCodeExists
+	}
+	s.links[k] = newLink(link)
+	return nil
+}
+
+func (s *Store) ReplaceExpired(_ context.Context, link *model.Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{link.Domain, link.Code}
+	if existing, ok := s.links[k]; ok {
+		if !existing.ExpiresAt.Before(link.CreatedAt) {
+			return storage.ErrCodeExists
+		}
+		s.lastGeneration++
+		s.generations[k] = append(s.generations[k], &model.LinkGeneration{
+			ID:         s.lastGeneration,
+			ArchivedAt: link.CreatedAt.UTC(),
+			Link:       *existing,
+		})
+	}
+	s.links[k] = newLink(link)
+	return nil
+}
+
+func (s *Store) ClickTimeseries(_ context.Context, domain, code string, buckets []storage.TimeBucket, includeBots bool) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make([]int, len(buckets))
+	link, ok := s.links[key{domain, code}]
+	if !ok {
+		return counts, nil
+	}
+	for _, click := range link.Clicks {
+		if click.IsBot && !includeBots {
+			continue
+		}
+		for i, bucket := range buckets {
+			if !click.Timestamp.Before(bucket.Start) && click.Timestamp.Before(bucket.End) {
+				counts[i]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) ListGenerations(_ context.Context, domain, code string, includeBots bool) ([]model.GenerationSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	archived := s.generations[key{domain, code}]
+	generations := make([]model.GenerationSummary, 0, len(archived))
+	for i := len(archived) - 1; i >= 0; i-- {
+		generations = append(generations, model.GenerationSummary{
+			ID:          archived[i].ID,
+			ArchivedAt:  archived[i].ArchivedAt,
+			LinkSummary: summarize(&archived[i].Link, includeBots),
+		})
+	}
+	return generations, nil
+}
+
+func (s *Store) Generation(_ context.Context, domain, code string, id int64) (*model.LinkGeneration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, generation := range s.generations[key{domain, code}] {
+		if generation.ID == id {
+			return &model.LinkGeneration{
+				ID:         generation.ID,
+				ArchivedAt: generation.ArchivedAt,
+				Link:       *cloneLink(&generation.Link),
+			}, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (s *Store) Get(_ context.Context, domain, code string) (*model.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[key{domain, code}]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return cloneLink(link), nil
+}
+
+func (s *Store) Resolve(_ context.Context, domain, code string) (*model.LinkTarget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[key{domain, code}]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &model.LinkTarget{
+		Domain:      link.Domain,
+		Code:        link.Code,
+		OwnerID:     link.OwnerID,
+		OriginalURL: link.OriginalURL,
+		ExpiresAt:   link.ExpiresAt,
+	}, nil
+}
+
+func (s *Store) RecordClick(_ context.Context, domain, code string, click model.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[key{domain, code}]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	addClick(link, click)
+	return nil
+}
+
+func (s *Store) RecordClicks(_ context.Context, events []storage.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		if link, ok := s.links[key{event.Domain, event.Code}]; ok {
+			addClick(link, event.Click)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Update(_ context.Context, domain, code string, update storage.LinkUpdate) (*model.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldKey := key{domain, code}
+	link, ok := s.links[oldKey]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	newKey := oldKey
+	if update.Code != nil && *update.Code != code {
+		newKey.code = *update.Code
+		if _, exists := s.links[newKey]; exists {
+			return nil, storage.ErrCodeExists
+		}
+	}
+
+	if update.OriginalURL != nil {
+		link.OriginalURL = *update.OriginalURL
+	}
+	if update.ExpiresAt != nil {
+		link.ExpiresAt = update.ExpiresAt.UTC()
+	}
+	if newKey != oldKey {
+		link.Code = newKey.code
+		delete(s.links, oldKey)
+		s.links[newKey] = link
+	}
+	return cloneLink(link), nil
+}
+
+func (s *Store) Delete(_ context.Context, domain, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{domain, code}
+	if _, ok := s.links[k]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.links, k)
+	return nil
+}
+
+func (s *Store) List(_ context.Context, opts storage.ListOptions) (storage.LinkPage, error) {
+	sortBy := opts.SortBy
+	if sortBy == "" {
+		sortBy = storage.SortCreated
+	}
+	less, ok := lessFuncs[sortBy]
+	if !ok {
+		return storage.LinkPage{}, errUnknownSort(sortBy)
+	}
+	var after *cursor
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor, sortBy)
+		if err != nil {
+			return storage.LinkPage{}, err
+		}
+		after = &c
+	}
+	match, err := filter(opts, time.Now())
+	if err != nil {
+		return storage.LinkPage{}, err
+	}
+
+	s.mu.RLock()
+	var links []model.LinkSummary
+	for _, link := range s.links {
+		if match(link) {
+			links = append(links, summarize(link, opts.IncludeBots))
+		}
+	}
+	s.mu.RUnlock()
+
+	ordered := func(a, b model.LinkSummary) bool {
+		if opts.Descending {
+			return less(b, a)
+		}
+		return less(a, b)
+	}
+	sort.Slice(links, func(i, j int) bool { return ordered(links[i], links[j]) })
+	if after != nil {
+		start := sort.Search(len(links), func(i int) bool { return ordered(after.link, links[i]) })
+		links = links[start:]
+	}
+
+	page := storage.LinkPage{Links: links}
+	if opts.Limit > 0 && len(links) > opts.Limit {
+		page.Links = links[:opts.Limit]
+		page.NextCursor = encodeCursor(sortBy, page.Links[opts.Limit-1])
+	}
+	if len(page.Links) == 0 {
+		page.Links = nil
+	}
+	return page, nil
+}
+
+func filter(opts storage.ListOptions, now time.Time) (func(*model.Link) bool, error) {
+	switch opts.Status {
+	case storage.StatusAny, storage.StatusActive, storage.StatusExpired:
+	default:
+		return nil, errUnknownStatus(opts.Status)
+	}
+	search := strings.ToLower(strings.TrimSpace(opts.Search))
+
+	return func(link *model.Link) bool {
+		switch {
+		case opts.OwnerID != 0 && link.OwnerID != opts.OwnerID,
+			opts.Domain != nil && link.Domain != *opts.Domain,
+			!opts.CreatedAfter.IsZero() && link.CreatedAt.Before(opts.CreatedAfter),
+			!opts.CreatedBefore.IsZero() && !link.CreatedAt.Before(opts.CreatedBefore),
+			!opts.ExpiresAfter.IsZero() && link.ExpiresAt.Before(opts.ExpiresAfter),
+			!opts.ExpiresBefore.IsZero() && !link.ExpiresAt.Before(opts.ExpiresBefore),
+			opts.Status == storage.StatusActive && !link.ExpiresAt.After(now),
+			opts.Status == storage.StatusExpired && link.ExpiresAt.After(now):
+			return false
+		}
+		if search != "" &&
+			!strings.Contains(strings.ToLower(link.Code), search) &&
+			!strings.Contains(strings.ToLower(link.OriginalURL), search) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// lessFuncs order links by the sort field, breaking ties by code and then
+// domain exactly like the SQL backends so cursors stay stable.
+var lessFuncs = map[storage.SortField]func(a, b model.LinkSummary) bool{
+	storage.SortCreated: func(a, b model.LinkSummary) bool {
+		return compareThen(a.CreatedAt.Compare(b.CreatedAt), a, b)
+	},
+	storage.SortExpires: func(a, b model.LinkSummary) bool {
+		return compareThen(a.ExpiresAt.Compare(b.ExpiresAt), a, b)
+	},
+	storage.SortClicks: func(a, b model.LinkSummary) bool {
+		return compareThen(a.TotalClicks-b.TotalClicks, a, b)
+	},
+	storage.SortUniqueVisitors: func(a, b model.LinkSummary) bool {
+		return compareThen(a.UniqueVisitors-b.UniqueVisitors, a, b)
+	},
+}
+
+func compareThen(cmp int, a, b model.LinkSummary) bool {
+	if cmp != 0 {
+		return cmp < 0
+	}
+	if a.Code != b.Code {
+		return a.Code < b.Code
+	}
+	return a.Domain < b.Domain
+}
+
+func newLink(link *model.Link) *model.Link {
+	return &model.Link{
+		Domain:      link.Domain,
+		Code:        link.Code,
+		OwnerID:     link.OwnerID,
+		OriginalURL: link.OriginalURL,
+		CreatedAt:   link.CreatedAt.UTC(),
+		ExpiresAt:   link.ExpiresAt.UTC(),
+		UniqueIPs:   make(map[string]struct{}),
+	}
+}
+
+func cloneLink(link *model.Link) *model.Link {
+	clone := *link
+	clone.Clicks = append([]model.Click(nil), link.Clicks...)
+	clone.UniqueIPs = make(map[string]struct{}, len(link.UniqueIPs))
+	for ip := range link.UniqueIPs {
+		clone.UniqueIPs[ip] = struct{}{}
+	}
+	return &clone
+}
+
+// addClick keeps Clicks ordered by timestamp, as Get returns them.
+func addClick(link *model.Link, click model.Click) {
+	click.Timestamp = click.Timestamp.UTC()
+	click.Visitor = click.VisitorKey()
+	i := sort.Search(len(link.Clicks), func(i int) bool {
+		return link.Clicks[i].Timestamp.After(click.Timestamp)
+	})
+	link.Clicks = append(link.Clicks, model.Click{})
+	copy(link.Clicks[i+1:], link.Clicks[i:])
+	link.Clicks[i] = click
+	if click.Visitor != "" && !click.IsBot {
+		link.UniqueIPs[click.Visitor] = struct{}{}
+	}
+}
+
+func summarize(link *model.Link, includeBots bool) model.LinkSummary {
+	clicks, visitors := 0, len(link.UniqueIPs)
+	if includeBots {
+		keys := make(map[string]struct{}, len(link.UniqueIPs))
+		for _, click := range link.Clicks {
+			if click.VisitorKey() != "" {
+				keys[click.VisitorKey()] = struct{}{}
+			}
+		}
+		clicks, visitors = len(link.Clicks), len(keys)
+	} else {
+		for _, click := range link.Clicks {
+			if !click.IsBot {
+				clicks++
+			}
+		}
+	}
+	return model.LinkSummary{
+		Domain:         link.Domain,
+		Code:           link.Code,
+		OwnerID:        link.OwnerID,
+		OriginalURL:    link.OriginalURL,
+		CreatedAt:      link.CreatedAt,
+		ExpiresAt:      link.ExpiresAt,
+		TotalClicks:    clicks,
+		UniqueVisitors: visitors,
+	}
+}
@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/memory"
+	"link-shortener/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store { return memory.New() })
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+func (s *Store) CreateAPIKey(ctx context.Context, key *model.APIKey, hash string) error {
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, key_hash, prefix, scopes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		nullID(key.UserID),
+		key.Name,
+		hash,
+		key.Prefix,
+		strings.Join(key.Scopes, " "),
+		key.CreatedAt.UTC(),
+	).Scan(&key.ID)
+}
+
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, name, prefix, scopes, created_at FROM api_keys WHERE key_hash = $1`,
+		hash,
+	)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrKeyNotFound
+	}
+	return key, err
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, name, prefix, scopes, created_at FROM api_keys ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrKeyNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (*model.APIKey, error) {
+	var key model.APIKey
+	var user sql.NullInt64
+	var scopes string
+	if err := row.Scan(&key.ID, &user, &key.Name, &key.Prefix, &scopes, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	key.UserID = user.Int64
+	key.CreatedAt = key.CreatedAt.UTC()
+	key.Scopes = strings.Fields(scopes)
+	return &key, nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// archiveLink copies a link and its clicks into a new generation and clears
+// the link's analytics, leaving the link row to be overwritten.
+func archiveLink(ctx context.Context, tx *sql.Tx, domain, code string, archivedAt time.Time) error {
+	var id int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO link_generations (domain, code, owner_id, original_url, created_at, expires_at, archived_at)
+		 SELECT domain, code, owner_id, original_url, created_at, expires_at, $1
+		 FROM links WHERE domain = $2 AND code = $3
+		 RETURNING id`,
+		archivedAt.UTC(),
+		domain,
+		code,
+	).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO generation_clicks (generation_id, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 SELECT $1, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM clicks WHERE domain = $2 AND code = $3 ORDER BY timestamp, id`,
+		id,
+		domain,
+		code,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clicks WHERE domain = $1 AND code = $2`, domain, code); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM unique_ips WHERE domain = $1 AND code = $2`, domain, code)
+	return err
+}
+
+func (s *Store) ListGenerations(ctx context.Context, domain, code string, includeBots bool) ([]model.GenerationSummary, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT g.id, g.archived_at, g.domain, g.code, g.owner_id, g.original_url, g.created_at, g.expires_at,
+			(SELECT COUNT(*) FROM generation_clicks c WHERE c.generation_id = g.id`+botFilter(includeBots)+`),
+			(SELECT COUNT(DISTINCT c.visitor) FROM generation_clicks c WHERE c.generation_id = g.id AND c.visitor <> ''`+botFilter(includeBots)+`)
+		 FROM link_generations g WHERE g.domain = $1 AND g.code = $2 ORDER BY g.id DESC`,
+		domain,
+		code,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var generations []model.GenerationSummary
+	for rows.Next() {
+		var generation model.GenerationSummary
+		var owner sql.NullInt64
+		if err := rows.Scan(
+			&generation.ID,
+			&generation.ArchivedAt,
+			&generation.Domain,
+			&generation.Code,
+			&owner,
+			&generation.OriginalURL,
+			&generation.CreatedAt,
+			&generation.ExpiresAt,
+			&generation.TotalClicks,
+			&generation.UniqueVisitors,
+		); err != nil {
+			return nil, err
+		}
+		generation.OwnerID = owner.Int64
+		generation.ArchivedAt = generation.ArchivedAt.UTC()
+		generation.CreatedAt = generation.CreatedAt.UTC()
+		generation.ExpiresAt = generation.ExpiresAt.UTC()
+		generations = append(generations, generation)
+	}
+	return generations, rows.Err()
+}
+
+func (s *Store) Generation(ctx context.Context, domain, code string, id int64) (*model.LinkGeneration, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, archived_at, domain, code, owner_id, original_url, created_at, expires_at
+		 FROM link_generations WHERE id = $1 AND domain = $2 AND code = $3`,
+		id,
+		domain,
+		code,
+	)
+
+	var generation model.LinkGeneration
+	var owner sql.NullInt64
+	if err := row.Scan(
+		&generation.ID,
+		&generation.ArchivedAt,
+		&generation.Domain,
+		&generation.Code,
+		&owner,
+		&generation.OriginalURL,
+		&generation.CreatedAt,
+		&generation.ExpiresAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	generation.OwnerID = owner.Int64
+	generation.ArchivedAt = generation.ArchivedAt.UTC()
+	generation.CreatedAt = generation.CreatedAt.UTC()
+	generation.ExpiresAt = generation.ExpiresAt.UTC()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM generation_clicks WHERE generation_id = $1 ORDER BY timestamp, id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	generation.UniqueIPs = make(map[string]struct{})
+	for rows.Next() {
+		var click model.Click
+		if err := rows.Scan(
+			&click.Timestamp,
+			&click.IP,
+			&click.Country,
+			&click.UserAgent,
+			&click.Referrer,
+			&click.Browser,
+			&click.BrowserVersion,
+			&click.OS,
+			&click.Device,
+			&click.IsBot,
+			&click.Visitor,
+		); err != nil {
+			return nil, err
+		}
+		click.Timestamp = click.Timestamp.UTC()
+		if click.VisitorKey() != "" && !click.IsBot {
+			generation.UniqueIPs[click.VisitorKey()] = struct{}{}
+		}
+		generation.Clicks = append(generation.Clicks, click)
+	}
+	return &generation, rows.Err()
+}
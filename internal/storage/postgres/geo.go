@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"link-shortener/internal/geo"
+)
+
+// geoPruneEvery is how many SaveLocation calls pass between deletions of
+// expired geo_cache rows.
+const geoPruneEvery = 256
+
+func (s *Store) LoadLocation(ctx context.Context, prefix string, now time.Time) (geo.Location, bool, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT country, region, city, asn, as_org
+		 FROM geo_cache WHERE prefix = $1 AND expires_at > $2`,
+		prefix,
+		now.UTC(),
+	)
+
+	var loc geo.Location
+	if err := row.Scan(&loc.Country, &loc.Region, &loc.City, &loc.ASN, &loc.ASOrg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return geo.Location{}, false, nil
+		}
+		return geo.Location{}, false, err
+	}
+	return loc, true, nil
+}
+
+func (s *Store) SaveLocation(ctx context.Context, prefix string, loc geo.Location, expires time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO geo_cache (prefix, country, region, city, asn, as_org, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (prefix) DO UPDATE SET
+		   country = excluded.country,
+		   region = excluded.region,
+		   city = excluded.city,
+		   asn = excluded.asn,
+		   as_org = excluded.as_org,
+		   expires_at = excluded.expires_at`,
+		prefix,
+		loc.Country,
+		loc.Region,
+		loc.City,
+		loc.ASN,
+		loc.ASOrg,
+		expires.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// LoadLocation only filters expired rows out, so delete them now and then.
+	if s.geoSaves.Add(1)%geoPruneEvery == 0 {
+		_, err = s.PruneLocations(ctx, time.Now())
+	}
+	return err
+}
+
+// PruneLocations deletes the geo_cache rows that expired by now.
+func (s *Store) PruneLocations(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM geo_cache WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+var sortColumns = map[storage.SortField]string{
+	storage.SortCreated:        "created_at",
+	storage.SortExpires:        "expires_at",
+	storage.SortClicks:         "total_clicks",
+	storage.SortUniqueVisitors: "unique_visitors",
+}
+
+type listCursor struct {
+	Sort   storage.SortField `json:"s"`
+	Value  string            `json:"v"`
+	Code   string            `json:"c"`
+	Domain string            `json:"d,omitempty"`
+}
+
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) (storage.LinkPage, error) {
+	query, args, err := buildListQuery(opts, time.Now())
+	if err != nil {
+		return storage.LinkPage{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return storage.LinkPage{}, err
+	}
+	defer rows.Close()
+
+	var links []model.LinkSummary
+	for rows.Next() {
+		var link model.LinkSummary
+		var owner sql.NullInt64
+		if err := rows.Scan(
+			&link.Domain,
+			&link.Code,
+			&owner,
+			&link.OriginalURL,
+			&link.CreatedAt,
+			&link.ExpiresAt,
+			&link.TotalClicks,
+			&link.UniqueVisitors,
+		); err != nil {
+			return storage.LinkPage{}, err
+		}
+		link.OwnerID = owner.Int64
+		link.CreatedAt = link.CreatedAt.UTC()
+		link.ExpiresAt = link.ExpiresAt.UTC()
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.LinkPage{}, err
+	}
+
+	page := storage.LinkPage{Links: links}
+	if opts.Limit > 0 && len(links) > opts.Limit {
+		page.Links = links[:opts.Limit]
+		page.NextCursor = encodeCursor(sortField(opts), page.Links[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// params numbers query arguments as they are added.
+type params []any
+
+func (p *params) add(value any) string {
+	*p = append(*p, value)
+	return "$" + strconv.Itoa(len(*p))
+}
+
+func buildListQuery(opts storage.ListOptions, now time.Time) (string, []any, error) {
+	var filters []string
+	var args params
+	if opts.OwnerID != 0 {
+		filters = append(filters, "l.owner_id = "+args.add(opts.OwnerID))
+	}
+	if opts.Domain != nil {
+		filters = append(filters, "l.domain = "+args.add(*opts.Domain))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		filters = append(filters, "l.created_at >= "+args.add(opts.CreatedAfter.UTC()))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		filters = append(filters, "l.created_at < "+args.add(opts.CreatedBefore.UTC()))
+	}
+	if !opts.ExpiresAfter.IsZero() {
+		filters = append(filters, "l.expires_at >= "+args.add(opts.ExpiresAfter.UTC()))
+	}
+	if !opts.ExpiresBefore.IsZero() {
+		filters = append(filters, "l.expires_at < "+args.add(opts.ExpiresBefore.UTC()))
+	}
+	switch opts.Status {
+	case storage.StatusAny:
+	case storage.StatusActive:
+		filters = append(filters, "l.expires_at > "+args.add(now.UTC()))
+	case storage.StatusExpired:
+		filters = append(filters, "l.expires_at <= "+args.add(now.UTC()))
+	default:
+		return "", nil, fmt.Errorf("unknown link status %q", opts.Status)
+	}
+	if search := strings.TrimSpace(opts.Search); search != "" {
+		pattern := args.add("%" + escapeLike(search) + "%")
+		filters = append(filters, fmt.Sprintf(`(l.code ILIKE %[1]s ESCAPE '\' OR l.original_url ILIKE %[1]s ESCAPE '\')`, pattern))
+	}
+
+	sort := sortField(opts)
+	column, ok := sortColumns[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort field %q", sort)
+	}
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	var outer []string
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor, sort)
+		if err != nil {
+			return "", nil, err
+		}
+		outer = append(outer, fmt.Sprintf(
+			"(%[1]s, code, domain) %[2]s (%[3]s, %[4]s, %[5]s)",
+			column,
+			comparison,
+			args.add(cursor.value),
+			args.add(cursor.code),
+			args.add(cursor.domain),
+		))
+	}
+
+	// Human totals are kept on the link by triggers; with bots they are
+	// counted per link.
+	totals := `l.click_count AS total_clicks, l.visitor_count AS unique_visitors`
+	if opts.IncludeBots {
+		// unique_ips only holds human visitors, so bots are counted from clicks.
+		totals = `(SELECT COUNT(*) FROM clicks c WHERE c.domain = l.domain AND c.code = l.code) AS total_clicks,
+				(SELECT COUNT(DISTINCT c.visitor) FROM clicks c WHERE c.domain = l.domain AND c.code = l.code AND c.visitor <> '') AS unique_visitors`
+	}
+
+	var query strings.Builder
+	query.WriteString(`SELECT domain, code, owner_id, original_url, created_at, expires_at, total_clicks, unique_visitors
+		FROM (
+			SELECT l.domain, l.code, l.owner_id, l.original_url, l.created_at, l.expires_at,
+				` + totals + `
+			FROM links l`)
+	if len(filters) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(filters, " AND "))
+	}
+	query.WriteString(") AS summaries")
+	if len(outer) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(outer, " AND "))
+	}
+	fmt.Fprintf(&query, " ORDER BY %[1]s %[2]s, code %[2]s, domain %[2]s", column, direction)
+	if opts.Limit > 0 {
+		query.WriteString(" LIMIT " + args.add(opts.Limit+1))
+	}
+	return query.String(), args, nil
+}
+
+func sortField(opts storage.ListOptions) storage.SortField {
+	if opts.SortBy == "" {
+		return storage.SortCreated
+	}
+	return opts.SortBy
+}
+
+type decodedCursor struct {
+	value  any
+	code   string
+	domain string
+}
+
+func encodeCursor(sort storage.SortField, link model.LinkSummary) string {
+	cursor := listCursor{Sort: sort, Code: link.Code, Domain: link.Domain}
+	switch sort {
+	case storage.SortCreated:
+		cursor.Value = link.CreatedAt.Format(time.RFC3339Nano)
+	case storage.SortExpires:
+		cursor.Value = link.ExpiresAt.Format(time.RFC3339Nano)
+	case storage.SortClicks:
+		cursor.Value = strconv.Itoa(link.TotalClicks)
+	case storage.SortUniqueVisitors:
+		cursor.Value = strconv.Itoa(link.UniqueVisitors)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, sort storage.SortField) (decodedCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return decodedCursor{}, storage.ErrInvalidCursor
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.Code == "" {
+		return decodedCursor{}, storage.ErrInvalidCursor
+	}
+	switch sort {
+	case storage.SortClicks, storage.SortUniqueVisitors:
+		n, err := strconv.ParseInt(cursor.Value, 10, 64)
+		if err != nil {
+			return decodedCursor{}, storage.ErrInvalidCursor
+		}
+		return decodedCursor{value: n, code: cursor.Code, domain: cursor.Domain}, nil
+	default:
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return decodedCursor{}, storage.ErrInvalidCursor
+		}
+		return decodedCursor{value: t.UTC(), code: cursor.Code, domain: cursor.Domain}, nil
+	}
+}
+
+// botFilter is the condition on clicks c that leaves bots out, or nothing
+// when they are included.
+func botFilter(includeBots bool) string {
+	if includeBots {
+		return ""
+	}
+	return " AND NOT c.is_bot"
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
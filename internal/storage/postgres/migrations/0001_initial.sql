@@ -0,0 +1,78 @@
+-- Baseline schema, matching SQLite migration 1. Codes and domains use the
+-- "C" collation so keyset pagination orders them bytewise like the other
+-- backends.
+
+CREATE TABLE workspaces (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE domains (
+	domain TEXT COLLATE "C" PRIMARY KEY,
+	workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL
+);
+
+CREATE TABLE users (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	role TEXT NOT NULL,
+	workspace_id BIGINT REFERENCES workspaces(id),
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE links (
+	domain TEXT COLLATE "C" NOT NULL DEFAULT '',
+	code TEXT COLLATE "C" NOT NULL,
+	owner_id BIGINT REFERENCES users(id),
+	original_url TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (domain, code)
+);
+
+CREATE TABLE clicks (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	domain TEXT COLLATE "C" NOT NULL DEFAULT '',
+	code TEXT COLLATE "C" NOT NULL,
+	timestamp TIMESTAMPTZ NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	country TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE unique_ips (
+	domain TEXT COLLATE "C" NOT NULL DEFAULT '',
+	code TEXT COLLATE "C" NOT NULL,
+	ip TEXT NOT NULL,
+	PRIMARY KEY (domain, code, ip),
+	FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE geo_cache (
+	prefix TEXT PRIMARY KEY,
+	country TEXT NOT NULL,
+	region TEXT NOT NULL DEFAULT '',
+	city TEXT NOT NULL DEFAULT '',
+	asn BIGINT NOT NULL DEFAULT 0,
+	as_org TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE api_keys (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_clicks_link_timestamp ON clicks(domain, code, timestamp);
+CREATE INDEX idx_links_created_at ON links(created_at);
+CREATE INDEX idx_links_expires_at ON links(expires_at);
+CREATE INDEX idx_links_owner_id ON links(owner_id);
+CREATE INDEX idx_domains_workspace_id ON domains(workspace_id);
@@ -0,0 +1,25 @@
+-- Links whose code expired and was claimed again are archived here with their
+-- clicks instead of being overwritten. Matches SQLite migration 2.
+
+CREATE TABLE link_generations (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	domain TEXT COLLATE "C" NOT NULL DEFAULT '',
+	code TEXT COLLATE "C" NOT NULL,
+	owner_id BIGINT REFERENCES users(id),
+	original_url TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	archived_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE generation_clicks (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	generation_id BIGINT NOT NULL REFERENCES link_generations(id) ON DELETE CASCADE,
+	timestamp TIMESTAMPTZ NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	country TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_link_generations_link ON link_generations(domain, code);
+CREATE INDEX idx_generation_clicks_generation ON generation_clicks(generation_id, timestamp);
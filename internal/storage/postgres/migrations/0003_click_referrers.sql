@@ -0,0 +1,5 @@
+-- Normalised referrer (host and path) of each click; empty means direct.
+-- Matches SQLite migration 3.
+
+ALTER TABLE clicks ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
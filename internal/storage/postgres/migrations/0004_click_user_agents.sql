@@ -0,0 +1,12 @@
+-- Browser family and major version, operating system and device class parsed
+-- from each click's user agent. Clicks recorded before this migration keep
+-- them empty.
+
+ALTER TABLE clicks ADD COLUMN browser TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN browser_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN os TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN device TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN browser TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN browser_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN os TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN device TEXT NOT NULL DEFAULT '';
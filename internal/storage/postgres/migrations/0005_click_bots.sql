@@ -0,0 +1,13 @@
+-- Clicks from crawlers, link previews and probes are flagged instead of
+-- counted. Bots never make an IP a unique visitor and the default totals
+-- leave them out; the new index serves those human-only counts.
+
+ALTER TABLE clicks ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE generation_clicks ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_clicks_link_bot_timestamp ON clicks(domain, code, is_bot, timestamp);
+
+-- Clicks whose user agent was already parsed as a bot. Unique visitors are
+-- left alone.
+UPDATE clicks SET is_bot = TRUE WHERE device = 'bot';
+UPDATE generation_clicks SET is_bot = TRUE WHERE device = 'bot';
@@ -0,0 +1,48 @@
+-- Listing sorted by clicks or unique visitors had to count every link's
+-- clicks before it could pick a page. Links now carry their human click and
+-- unique visitor totals, kept up to date by triggers, and indexes in listing
+-- order so such a page is read straight off the index. The counts belong to
+-- the link row and follow it through a rename, so the cascaded updates of
+-- clicks.code do not touch them.
+
+ALTER TABLE links
+	ADD COLUMN click_count BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN visitor_count BIGINT NOT NULL DEFAULT 0;
+
+UPDATE links SET
+	click_count = (SELECT COUNT(*) FROM clicks c WHERE c.domain = links.domain AND c.code = links.code AND NOT c.is_bot),
+	visitor_count = (SELECT COUNT(*) FROM unique_ips u WHERE u.domain = links.domain AND u.code = links.code);
+
+CREATE INDEX idx_links_click_count ON links(click_count, code, domain);
+CREATE INDEX idx_links_visitor_count ON links(visitor_count, code, domain);
+
+CREATE FUNCTION count_link_clicks() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') AND NOT OLD.is_bot THEN
+		UPDATE links SET click_count = click_count - 1 WHERE domain = OLD.domain AND code = OLD.code;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') AND NOT NEW.is_bot THEN
+		UPDATE links SET click_count = click_count + 1 WHERE domain = NEW.domain AND code = NEW.code;
+	END IF;
+	RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER clicks_count AFTER INSERT OR DELETE ON clicks
+	FOR EACH ROW EXECUTE FUNCTION count_link_clicks();
+CREATE TRIGGER clicks_count_is_bot AFTER UPDATE OF is_bot ON clicks
+	FOR EACH ROW WHEN (OLD.is_bot IS DISTINCT FROM NEW.is_bot) EXECUTE FUNCTION count_link_clicks();
+
+CREATE FUNCTION count_link_visitors() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		UPDATE links SET visitor_count = visitor_count - 1 WHERE domain = OLD.domain AND code = OLD.code;
+	ELSE
+		UPDATE links SET visitor_count = visitor_count + 1 WHERE domain = NEW.domain AND code = NEW.code;
+	END IF;
+	RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER unique_ips_count AFTER INSERT OR DELETE ON unique_ips
+	FOR EACH ROW EXECUTE FUNCTION count_link_visitors();
@@ -0,0 +1,433 @@
+// Package postgres is a storage.Store backed by a PostgreSQL server, for
+// deployments that outgrow a single SQLite file.
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+type Store struct {
+	db *sql.DB
+
+	geoSaves atomic.Uint64
+}
+
+// New connects to the database at dsn, a postgres:// URL or key=value
+// connection string, and applies any pending migrations.
+func New(dsn string) (*Store, error) {
+	db, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *Store) Save(ctx context.Context, link *model.Link) error {
+	return insertLink(ctx, s.db, link)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertLink(ctx context.Context, db execer, link *model.Link) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO links (domain, code, owner_id, original_url, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		link.Domain,
+		link.Code,
+		nullID(link.OwnerID),
+		link.OriginalURL,
+		link.CreatedAt.UTC(),
+		link.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrCodeExists
+		}
+		return err
+	}
+	return nil
+}
+
+// ReplaceExpired locks the link holding the code, so of several concurrent
+// replacements only the first finds it expired. When no link holds the code,
+// the primary key decides between concurrent inserts.
+func (s *Store) ReplaceExpired(ctx context.Context, link *model.Link) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var expires time.Time
+	err = tx.QueryRowContext(ctx,
+		`SELECT expires_at FROM links WHERE domain = $1 AND code = $2 FOR UPDATE`,
+		link.Domain,
+		link.Code,
+	).Scan(&expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := insertLink(ctx, tx, link); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	if !expires.Before(link.CreatedAt) {
+		return storage.ErrCodeExists
+	}
+
+	if err := archiveLink(ctx, tx, link.Domain, link.Code, link.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE links SET owner_id = $1, original_url = $2, created_at = $3, expires_at = $4
+		 WHERE domain = $5 AND code = $6`,
+		nullID(link.OwnerID),
+		link.OriginalURL,
+		link.CreatedAt.UTC(),
+		link.ExpiresAt.UTC(),
+		link.Domain,
+		link.Code,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Get(ctx context.Context, domain, code string) (*model.Link, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT domain, code, owner_id, original_url, created_at, expires_at
+		 FROM links WHERE domain = $1 AND code = $2`,
+		domain,
+		code,
+	)
+
+	var link model.Link
+	var owner sql.NullInt64
+	if err := row.Scan(&link.Domain, &link.Code, &owner, &link.OriginalURL, &link.CreatedAt, &link.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	link.OwnerID = owner.Int64
+	link.CreatedAt = link.CreatedAt.UTC()
+	link.ExpiresAt = link.ExpiresAt.UTC()
+
+	var err error
+	if link.Clicks, err = s.loadClicks(ctx, domain, code); err != nil {
+		return nil, err
+	}
+	if link.UniqueIPs, err = s.loadUniqueIPs(ctx, domain, code); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *Store) Resolve(ctx context.Context, domain, code string) (*model.LinkTarget, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT domain, code, owner_id, original_url, expires_at
+		 FROM links WHERE domain = $1 AND code = $2`,
+		domain,
+		code,
+	)
+
+	var target model.LinkTarget
+	var owner sql.NullInt64
+	if err := row.Scan(&target.Domain, &target.Code, &owner, &target.OriginalURL, &target.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	target.OwnerID = owner.Int64
+	target.ExpiresAt = target.ExpiresAt.UTC()
+	return &target, nil
+}
+
+func (s *Store) RecordClick(ctx context.Context, domain, code string, click model.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// FOR KEY SHARE keeps the link from being deleted or renamed until the
+	// click is in, without blocking other clicks on it.
+	var exists int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT 1 FROM links WHERE domain = $1 AND code = $2 FOR KEY SHARE`,
+		domain,
+		code,
+	).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO clicks (domain, code, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		domain,
+		code,
+		click.Timestamp.UTC(),
+		click.IP,
+		click.Country,
+		click.UserAgent,
+		click.Referrer,
+		click.Browser,
+		click.BrowserVersion,
+		click.OS,
+		click.Device,
+		click.IsBot,
+		click.VisitorKey(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if click.VisitorKey() != "" && !click.IsBot {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO unique_ips (domain, code, visitor) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			domain,
+			code,
+			click.VisitorKey(),
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RecordClicks(ctx context.Context, events []storage.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertClick, err := tx.PrepareContext(ctx,
+		`INSERT INTO clicks (domain, code, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 SELECT $1::text, $2::text, $3::timestamptz, $4::text, $5::text, $6::text, $7::text,
+			$8::text, $9::text, $10::text, $11::text, $12::boolean, $13::text
+		 WHERE EXISTS (SELECT 1 FROM links WHERE domain = $1 AND code = $2)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insertClick.Close()
+
+	insertVisitor, err := tx.PrepareContext(ctx,
+		`INSERT INTO unique_ips (domain, code, visitor)
+		 SELECT $1::text, $2::text, $3::text
+		 WHERE EXISTS (SELECT 1 FROM links WHERE domain = $1 AND code = $2)
+		 ON CONFLICT DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insertVisitor.Close()
+
+	// The counting triggers lock each link's row; taking them in one order
+	// keeps concurrent batches from deadlocking.
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(a, b storage.ClickEvent) int {
+		return cmp.Or(strings.Compare(a.Domain, b.Domain), strings.Compare(a.Code, b.Code))
+	})
+	for _, event := range events {
+		click := event.Click
+		if _, err := insertClick.ExecContext(ctx,
+			event.Domain,
+			event.Code,
+			click.Timestamp.UTC(),
+			click.IP,
+			click.Country,
+			click.UserAgent,
+			click.Referrer,
+			click.Browser,
+			click.BrowserVersion,
+			click.OS,
+			click.Device,
+			click.IsBot,
+			click.VisitorKey(),
+		); err != nil {
+			return err
+		}
+		if click.VisitorKey() == "" || click.IsBot {
+			continue
+		}
+		if _, err := insertVisitor.ExecContext(ctx, event.Domain, event.Code, click.VisitorKey()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Update(ctx context.Context, domain, code string, update storage.LinkUpdate) (*model.Link, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var originalURL string
+	var expires time.Time
+	if err := tx.QueryRowContext(ctx,
+		`SELECT original_url, expires_at FROM links WHERE domain = $1 AND code = $2 FOR UPDATE`,
+		domain,
+		code,
+	).Scan(&originalURL, &expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if update.OriginalURL != nil {
+		originalURL = *update.OriginalURL
+	}
+	if update.ExpiresAt != nil {
+		expires = *update.ExpiresAt
+	}
+	newCode := code
+	if update.Code != nil {
+		newCode = *update.Code
+	}
+	// Clicks and unique IPs follow a rename through ON UPDATE CASCADE.
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE links SET code = $1, original_url = $2, expires_at = $3 WHERE domain = $4 AND code = $5`,
+		newCode,
+		originalURL,
+		expires.UTC(),
+		domain,
+		code,
+	); err != nil {
+		if isUniqueViolation(err) {
+			return nil, storage.ErrCodeExists
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, domain, newCode)
+}
+
+// Delete relies on the cascading foreign keys to remove clicks and unique IPs.
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM links WHERE domain = $1 AND code = $2`, domain, code)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) loadClicks(ctx context.Context, domain, code string) ([]model.Click, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM clicks WHERE domain = $1 AND code = $2 ORDER BY timestamp, id`,
+		domain,
+		code,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicks []model.Click
+	for rows.Next() {
+		var click model.Click
+		if err := rows.Scan(
+			&click.Timestamp,
+			&click.IP,
+			&click.Country,
+			&click.UserAgent,
+			&click.Referrer,
+			&click.Browser,
+			&click.BrowserVersion,
+			&click.OS,
+			&click.Device,
+			&click.IsBot,
+			&click.Visitor,
+		); err != nil {
+			return nil, err
+		}
+		click.Timestamp = click.Timestamp.UTC()
+		clicks = append(clicks, click)
+	}
+	return clicks, rows.Err()
+}
+
+func (s *Store) loadUniqueIPs(ctx context.Context, domain, code string) (map[string]struct{}, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT visitor FROM unique_ips WHERE domain = $1 AND code = $2`, domain, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unique := make(map[string]struct{})
+	for rows.Next() {
+		var visitor string
+		if err := rows.Scan(&visitor); err != nil {
+			return nil, err
+		}
+		if visitor != "" {
+			unique[visitor] = struct{}{}
+		}
+	}
+	return unique, rows.Err()
+}
+
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"link-shortener/internal/storage"
+)
+
+// ClickTimeseries joins the buckets, passed in as a VALUES table, to the
+// clicks index on (domain, code, is_bot, timestamp). The LEFT JOIN keeps
+// buckets without clicks.
+func (s *Store) ClickTimeseries(ctx context.Context, domain, code string, buckets []storage.TimeBucket, includeBots bool) ([]int, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	var query strings.Builder
+	var args params
+	query.WriteString(`WITH buckets(idx, bucket_start, bucket_end) AS (VALUES `)
+	for i, bucket := range buckets {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "(%d, %s::timestamptz, %s::timestamptz)", i, args.add(bucket.Start.UTC()), args.add(bucket.End.UTC()))
+	}
+	fmt.Fprintf(&query, `)
+		SELECT b.idx, COUNT(c.id)
+		FROM buckets b
+		LEFT JOIN clicks c ON c.domain = %s AND c.code = %s
+			AND c.timestamp >= b.bucket_start AND c.timestamp < b.bucket_end`+botFilter(includeBots)+`
+		GROUP BY b.idx`, args.add(domain), args.add(code))
+
+	rows, err := s.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, len(buckets))
+	for rows.Next() {
+		var idx, count int
+		if err := rows.Scan(&idx, &count); err != nil {
+			return nil, err
+		}
+		counts[idx] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+func (s *Store) CreateUser(ctx context.Context, user *model.User) error {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO users (name, role, workspace_id, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		user.Name,
+		user.Role,
+		nullID(user.WorkspaceID),
+		user.CreatedAt.UTC(),
+	).Scan(&user.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrUserExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) UserByID(ctx context.Context, id int64) (*model.User, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, role, workspace_id, created_at FROM users WHERE id = $1`, id)
+	return userOrNotFound(scanUser(row))
+}
+
+func (s *Store) UserByName(ctx context.Context, name string) (*model.User, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, role, workspace_id, created_at FROM users WHERE name = $1`, name)
+	return userOrNotFound(scanUser(row))
+}
+
+func userOrNotFound(user *model.User, err error) (*model.User, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]model.User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, role, workspace_id, created_at FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (s *Store) AssignUnownedLinks(ctx context.Context, ownerID int64) (int64, error) {
+	if _, err := s.UserByID(ctx, ownerID); err != nil {
+		return 0, err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE links SET owner_id = $1 WHERE owner_id IS NULL`, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanUser(row scanner) (*model.User, error) {
+	var user model.User
+	var workspace sql.NullInt64
+	if err := row.Scan(&user.ID, &user.Name, &user.Role, &workspace, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	user.WorkspaceID = workspace.Int64
+	user.CreatedAt = user.CreatedAt.UTC()
+	return &user, nil
+}
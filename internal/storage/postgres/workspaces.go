@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+func (s *Store) CreateWorkspace(ctx context.Context, workspace *model.Workspace) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO workspaces (name, created_at) VALUES ($1, $2) RETURNING id`,
+		workspace.Name,
+		workspace.CreatedAt.UTC(),
+	).Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrWorkspaceExists
+		}
+		return err
+	}
+	for i, domain := range workspace.Domains {
+		if err := insertDomain(ctx, tx, id, domain, i); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	workspace.ID = id
+	return nil
+}
+
+func (s *Store) WorkspaceByID(ctx context.Context, id int64) (*model.Workspace, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM workspaces WHERE id = $1`, id)
+	return s.scanWorkspace(ctx, row)
+}
+
+func (s *Store) WorkspaceByDomain(ctx context.Context, domain string) (*model.Workspace, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT w.id, w.name, w.created_at
+		 FROM workspaces w JOIN domains d ON d.workspace_id = w.id
+		 WHERE d.domain = $1`,
+		domain,
+	)
+	return s.scanWorkspace(ctx, row)
+}
+
+func (s *Store) ListWorkspaces(ctx context.Context) ([]model.Workspace, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, created_at FROM workspaces ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workspaces []model.Workspace
+	for rows.Next() {
+		var workspace model.Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt); err != nil {
+			return nil, err
+		}
+		workspace.CreatedAt = workspace.CreatedAt.UTC()
+		workspaces = append(workspaces, workspace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range workspaces {
+		domains, err := s.loadDomains(ctx, workspaces[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		workspaces[i].Domains = domains
+	}
+	return workspaces, nil
+}
+
+func (s *Store) AddDomain(ctx context.Context, workspaceID int64, domain string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the workspace row serialises concurrent additions, which
+	// would otherwise pick the same position.
+	var exists int
+	if err := tx.QueryRowContext(ctx, `SELECT 1 FROM workspaces WHERE id = $1 FOR UPDATE`, workspaceID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrWorkspaceNotFound
+		}
+		return err
+	}
+	var position int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(position) + 1, 0) FROM domains WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&position); err != nil {
+		return err
+	}
+	if err := insertDomain(ctx, tx, workspaceID, domain, position); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertDomain(ctx context.Context, tx *sql.Tx, workspaceID int64, domain string, position int) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO domains (domain, workspace_id, position) VALUES ($1, $2, $3)`,
+		domain,
+		workspaceID,
+		position,
+	)
+	if err != nil && isUniqueViolation(err) {
+		return storage.ErrDomainExists
+	}
+	return err
+}
+
+func (s *Store) loadDomains(ctx context.Context, workspaceID int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT domain FROM domains WHERE workspace_id = $1 ORDER BY position`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []string{}
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// scanWorkspace reads a workspace row and loads its domains. A missing row is
+// ErrWorkspaceNotFound.
+func (s *Store) scanWorkspace(ctx context.Context, row scanner) (*model.Workspace, error) {
+	var workspace model.Workspace
+	if err := row.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	workspace.CreatedAt = workspace.CreatedAt.UTC()
+	domains, err := s.loadDomains(ctx, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+	workspace.Domains = domains
+	return &workspace, nil
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"link-shortener/internal/model"
+)
+
+const anonymizeBatchSize = 500
+
+// AnonymizeClicks rewrites clicks in batches inside one transaction, so a
+// failure leaves the database as it was.
+func (s *Store) AnonymizeClicks(ctx context.Context, anonymize func(click *model.Click)) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, table := range []string{"clicks", "generation_clicks"} {
+		n, err := anonymizeTable(ctx, tx, table, anonymize)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM unique_ips`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO unique_ips (domain, code, visitor)
+		 SELECT DISTINCT domain, code, visitor FROM clicks WHERE visitor <> '' AND is_bot = 0`,
+	); err != nil {
+		return 0, err
+	}
+	return total, tx.Commit()
+}
+
+func anonymizeTable(ctx context.Context, tx *sql.Tx, table string, anonymize func(click *model.Click)) (int64, error) {
+	type row struct {
+		id    int64
+		click model.Click
+	}
+	var n, after int64
+	for {
+		rows, err := tx.QueryContext(ctx,
+			fmt.Sprintf(`SELECT id, timestamp, ip FROM %s WHERE id > ? AND ip <> '' AND visitor = ip ORDER BY id LIMIT ?`, table),
+			after,
+			anonymizeBatchSize,
+		)
+		if err != nil {
+			return 0, err
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			var timestamp string
+			if err := rows.Scan(&r.id, &timestamp, &r.click.IP); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if r.click.Timestamp, err = parseTime(timestamp); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		if len(batch) == 0 {
+			return n, nil
+		}
+
+		for _, r := range batch {
+			anonymize(&r.click)
+			if _, err := tx.ExecContext(ctx,
+				fmt.Sprintf(`UPDATE %s SET ip = ?, visitor = ? WHERE id = ?`, table),
+				r.click.IP,
+				r.click.Visitor,
+				r.id,
+			); err != nil {
+				return 0, err
+			}
+			after = r.id
+		}
+		n += int64(len(batch))
+	}
+}
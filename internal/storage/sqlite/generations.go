@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// archiveLink copies a link and its clicks into a new generation and clears
+// the link's analytics, leaving the link row to be overwritten.
+func archiveLink(ctx context.Context, tx *sql.Tx, domain, code string, archivedAt time.Time) error {
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO link_generations (domain, code, owner_id, original_url, created_at, expires_at, archived_at)
+		 SELECT domain, code, owner_id, original_url, created_at, expires_at, ?
+		 FROM links WHERE domain = ? AND code = ?`,
+		formatTime(archivedAt),
+		domain,
+		code,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO generation_clicks (generation_id, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 SELECT ?, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM clicks WHERE domain = ? AND code = ? ORDER BY timestamp, id`,
+		id,
+		domain,
+		code,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clicks WHERE domain = ? AND code = ?`, domain, code); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM unique_ips WHERE domain = ? AND code = ?`, domain, code)
+	return err
+}
+
+func (s *Store) ListGenerations(ctx context.Context, domain, code string, includeBots bool) ([]model.GenerationSummary, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT g.id, g.archived_at, g.domain, g.code, g.owner_id, g.original_url, g.created_at, g.expires_at,
+			(SELECT COUNT(*) FROM generation_clicks c WHERE c.generation_id = g.id`+botFilter(includeBots)+`),
+			(SELECT COUNT(DISTINCT c.visitor) FROM generation_clicks c WHERE c.generation_id = g.id AND c.visitor <> ''`+botFilter(includeBots)+`)
+		 FROM link_generations g WHERE g.domain = ? AND g.code = ? ORDER BY g.id DESC`,
+		domain,
+		code,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var generations []model.GenerationSummary
+	for rows.Next() {
+		var generation model.GenerationSummary
+		var owner sql.NullInt64
+		var archived, created, expires string
+		if err := rows.Scan(
+			&generation.ID,
+			&archived,
+			&generation.Domain,
+			&generation.Code,
+			&owner,
+			&generation.OriginalURL,
+			&created,
+			&expires,
+			&generation.TotalClicks,
+			&generation.UniqueVisitors,
+		); err != nil {
+			return nil, err
+		}
+		generation.OwnerID = owner.Int64
+		if generation.ArchivedAt, err = parseTime(archived); err != nil {
+			return nil, err
+		}
+		if generation.CreatedAt, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		if generation.ExpiresAt, err = parseTime(expires); err != nil {
+			return nil, err
+		}
+		generations = append(generations, generation)
+	}
+	return generations, rows.Err()
+}
+
+func (s *Store) Generation(ctx context.Context, domain, code string, id int64) (*model.LinkGeneration, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, archived_at, domain, code, owner_id, original_url, created_at, expires_at
+		 FROM link_generations WHERE id = ? AND domain = ? AND code = ?`,
+		id,
+		domain,
+		code,
+	)
+
+	var generation model.LinkGeneration
+	var owner sql.NullInt64
+	var archived, created, expires string
+	if err := row.Scan(
+		&generation.ID,
+		&archived,
+		&generation.Domain,
+		&generation.Code,
+		&owner,
+		&generation.OriginalURL,
+		&created,
+		&expires,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	var err error
+	generation.OwnerID = owner.Int64
+	if generation.ArchivedAt, err = parseTime(archived); err != nil {
+		return nil, err
+	}
+	if generation.CreatedAt, err = parseTime(created); err != nil {
+		return nil, err
+	}
+	if generation.ExpiresAt, err = parseTime(expires); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM generation_clicks WHERE generation_id = ? ORDER BY timestamp, id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	generation.UniqueIPs = make(map[string]struct{})
+	for rows.Next() {
+		var click model.Click
+		var timestamp string
+		if err := rows.Scan(
+			&timestamp,
+			&click.IP,
+			&click.Country,
+			&click.UserAgent,
+			&click.Referrer,
+			&click.Browser,
+			&click.BrowserVersion,
+			&click.OS,
+			&click.Device,
+			&click.IsBot,
+			&click.Visitor,
+		); err != nil {
+			return nil, err
+		}
+		if click.Timestamp, err = parseTime(timestamp); err != nil {
+			return nil, err
+		}
+		if click.VisitorKey() != "" && !click.IsBot {
+			generation.UniqueIPs[click.VisitorKey()] = struct{}{}
+		}
+		generation.Clicks = append(generation.Clicks, click)
+	}
+	return &generation, rows.Err()
+}
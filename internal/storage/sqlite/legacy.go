@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Databases created before versioned migrations were upgraded in place at
+// startup. upgradeLegacySchema replays those ad-hoc upgrades so that such a
+// database matches the state migration 1 expects before it is recorded.
+
+// legacyLinkTables is the shape of the link tables as of migration 1, used to
+// rebuild tables whose codes were still globally unique.
+var legacyLinkTables = []string{
+	`CREATE TABLE links (
+		domain TEXT NOT NULL DEFAULT '',
+		code TEXT NOT NULL,
+		owner_id INTEGER REFERENCES users(id),
+		original_url TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		PRIMARY KEY (domain, code)
+	);`,
+	`CREATE TABLE clicks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		domain TEXT NOT NULL DEFAULT '',
+		code TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		ip TEXT,
+		country TEXT,
+		user_agent TEXT,
+		FOREIGN KEY(domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+	);`,
+	`CREATE TABLE unique_ips (
+		domain TEXT NOT NULL DEFAULT '',
+		code TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		PRIMARY KEY (domain, code, ip),
+		FOREIGN KEY(domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+	);`,
+}
+
+// legacyParentTables are created up front because renaming a table fails
+// while any table references one that does not exist yet.
+var legacyParentTables = []string{
+	`CREATE TABLE IF NOT EXISTS workspaces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		role TEXT NOT NULL,
+		workspace_id INTEGER REFERENCES workspaces(id),
+		created_at TEXT NOT NULL
+	);`,
+}
+
+func upgradeLegacySchema(tx *sql.Tx) error {
+	for _, query := range legacyParentTables {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"links", "owner_id", "INTEGER REFERENCES users(id)"},
+		{"api_keys", "user_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"},
+		{"users", "workspace_id", "INTEGER REFERENCES workspaces(id)"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return upgradeLinkDomains(tx)
+}
+
+// upgradeLinkDomains rebuilds the link tables of databases created when codes
+// were globally unique. SQLite cannot change a primary key in place, so the
+// old tables are renamed away, recreated and copied into the default domain.
+func upgradeLinkDomains(tx *sql.Tx) error {
+	ok, err := hasColumn(tx, "links", "domain")
+	if err != nil || ok {
+		return err
+	}
+
+	for _, table := range []string{"links", "clicks", "unique_ips"} {
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %[1]s RENAME TO %[1]s_old`, table)); err != nil {
+			return err
+		}
+	}
+	for _, query := range legacyLinkTables {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	copies := []string{
+		`INSERT INTO links (domain, code, owner_id, original_url, created_at, expires_at)
+		 SELECT '', code, owner_id, original_url, created_at, expires_at FROM links_old`,
+		`INSERT INTO clicks (id, domain, code, timestamp, ip, country, user_agent)
+		 SELECT id, '', code, timestamp, ip, country, user_agent FROM clicks_old`,
+		`INSERT INTO unique_ips (domain, code, ip) SELECT '', code, ip FROM unique_ips_old`,
+		`DROP TABLE clicks_old`,
+		`DROP TABLE unique_ips_old`,
+		`DROP TABLE links_old`,
+	}
+	for _, query := range copies {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds the column to an existing table that lacks it.
+// Existing rows get NULL. Tables that do not exist yet are left to migration 1.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	exists, err := tableExists(tx, table)
+	if err != nil || !exists {
+		return err
+	}
+	ok, err := hasColumn(tx, table, column)
+	if err != nil || ok {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	var n int
+	err := tx.QueryRow(
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+		table,
+		column,
+	).Scan(&n)
+	return n > 0, err
+}
+
+func tableExists(q queryRower, table string) (bool, error) {
+	var n int
+	err := q.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		table,
+	).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"link-shortener/internal/storage"
+)
+
+// baselineSchema is what ensureSchema created before migrations existed:
+// codes were globally unique and there were no users, keys or workspaces.
+var baselineSchema = []string{
+	`CREATE TABLE links (
+		code TEXT PRIMARY KEY,
+		original_url TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);`,
+	`CREATE TABLE clicks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		ip TEXT,
+		country TEXT,
+		user_agent TEXT,
+		FOREIGN KEY(code) REFERENCES links(code) ON DELETE CASCADE
+	);`,
+	`CREATE TABLE unique_ips (
+		code TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		PRIMARY KEY (code, ip),
+		FOREIGN KEY(code) REFERENCES links(code) ON DELETE CASCADE
+	);`,
+}
+
+// baselineRows are written the way the baseline did, with RFC 3339
+// timestamps that only have as many fractional digits as they need.
+var baselineRows = []string{
+	`INSERT INTO links VALUES
+		('launch', 'https://example.com/launch', '2025-01-02T03:04:05.5Z', '2035-01-02T03:04:05Z'),
+		('early', 'https://example.com/early', '2025-01-02T03:04:05Z', '2035-01-02T03:04:05Z'),
+		('old', 'https://example.com/old', '2025-01-01T00:00:00Z', '2025-02-01T00:00:00Z')`,
+	`INSERT INTO clicks (code, timestamp, ip, country, user_agent) VALUES
+		('launch', '2025-01-03T10:00:00.25Z', '203.0.113.7', 'DE', 'Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0'),
+		('launch', '2025-01-03T11:00:00Z', '203.0.113.7', 'DE', 'Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0'),
+		('launch', '2025-01-04T09:30:00Z', '198.51.100.2', 'FR', 'Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1'),
+		('old', '2025-01-05T00:00:00Z', '192.0.2.1', 'US', 'Mozilla/5.0')`,
+	`INSERT INTO unique_ips VALUES ('launch', '203.0.113.7'), ('launch', '198.51.100.2'), ('old', '192.0.2.1')`,
+}
+
+func newBaselineDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, query := range slices.Concat(baselineSchema, baselineRows) {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	return path
+}
+
+func TestUpgradeBaselineDatabase(t *testing.T) {
+	path := newBaselineDB(t)
+
+	status, err := MigrationStatus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if !m.AppliedAt.IsZero() {
+			t.Fatalf("migration %d applied before the upgrade", m.Version)
+		}
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	ctx := t.Context()
+
+	link, err := s.Get(ctx, "", "launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Domain != "" || link.OriginalURL != "https://example.com/launch" || link.OwnerID != 0 {
+		t.Fatalf("link = %+v", link)
+	}
+	if want := time.Date(2025, 1, 2, 3, 4, 5, 500000000, time.UTC); !link.CreatedAt.Equal(want) {
+		t.Fatalf("created = %v, want %v", link.CreatedAt, want)
+	}
+	if len(link.Clicks) != 3 {
+		t.Fatalf("clicks = %d, want 3", len(link.Clicks))
+	}
+	first := link.Clicks[0]
+	if first.IP != "203.0.113.7" || first.Visitor != "203.0.113.7" || first.Country != "DE" || first.IsBot {
+		t.Fatalf("first click = %+v", first)
+	}
+	if want := time.Date(2025, 1, 3, 10, 0, 0, 250000000, time.UTC); !first.Timestamp.Equal(want) {
+		t.Fatalf("first click at %v, want %v", first.Timestamp, want)
+	}
+	if len(link.UniqueIPs) != 2 {
+		t.Fatalf("unique visitors = %v, want 2", link.UniqueIPs)
+	}
+
+	page, err := s.List(ctx, storage.ListOptions{SortBy: storage.SortCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []string
+	for _, l := range page.Links {
+		summaries = append(summaries, l.Code)
+		if l.Code == "launch" && (l.TotalClicks != 3 || l.UniqueVisitors != 2) {
+			t.Fatalf("launch summary = %+v, want 3 clicks from 2 visitors", l)
+		}
+	}
+	if !slices.Equal(summaries, []string{"old", "early", "launch"}) {
+		t.Fatalf("listed %v, want [old early launch]", summaries)
+	}
+
+	// Stored times were padded to the fixed-width layout, so text comparisons
+	// order them by time even within a second.
+	for _, column := range []string{"links.created_at", "links.expires_at", "clicks.timestamp"} {
+		table, col, _ := strings.Cut(column, ".")
+		var n int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE length(`+col+`) != ?`, len(formatTime(time.Now()))).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatalf("%s has %d values not in timeLayout", column, n)
+		}
+	}
+	page, err = s.List(ctx, storage.ListOptions{CreatedAfter: time.Date(2025, 1, 2, 3, 4, 5, 250000000, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Links) != 1 || page.Links[0].Code != "launch" {
+		t.Fatalf("created after 03:04:05.25 listed %v, want [launch]", page.Links)
+	}
+
+	// Codes are per domain now, and the rebuilt foreign keys still cascade.
+	if err := s.Delete(ctx, "", "old"); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"clicks", "unique_ips"} {
+		var n int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE code = 'old'`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatalf("%s kept %d rows of the deleted link", table, n)
+		}
+	}
+	rows, err := s.db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Fatal("foreign_key_check reported violations")
+	}
+	rows.Close()
+	for _, table := range []string{"links_old", "clicks_old", "unique_ips_old"} {
+		if ok, err := tableExists(s.db, table); err != nil || ok {
+			t.Fatalf("%s left behind (err %v)", table, err)
+		}
+	}
+
+	var recorded int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != len(status) {
+		t.Fatalf("schema_migrations has %d rows, want %d", recorded, len(status))
+	}
+	status, err = MigrationStatus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if m.AppliedAt.IsZero() {
+			t.Fatalf("migration %d_%s still pending", m.Version, m.Name)
+		}
+	}
+	if applied, err := Migrate(path); err != nil || len(applied) != 0 {
+		t.Fatalf("second run applied %v, %v; want nothing", applied, err)
+	}
+}
@@ -0,0 +1,243 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+var sortColumns = map[storage.SortField]string{
+	storage.SortCreated:        "created_at",
+	storage.SortExpires:        "expires_at",
+	storage.SortClicks:         "total_clicks",
+	storage.SortUniqueVisitors: "unique_visitors",
+}
+
+type listCursor struct {
+	Sort   storage.SortField `json:"s"`
+	Value  string            `json:"v"`
+	Code   string            `json:"c"`
+	Domain string            `json:"d,omitempty"`
+}
+
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) (storage.LinkPage, error) {
+	query, args, err := buildListQuery(opts, time.Now())
+	if err != nil {
+		return storage.LinkPage{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return storage.LinkPage{}, err
+	}
+	defer rows.Close()
+
+	var links []model.LinkSummary
+	for rows.Next() {
+		var link model.LinkSummary
+		var owner sql.NullInt64
+		var created, expires string
+		if err := rows.Scan(
+			&link.Domain,
+			&link.Code,
+			&owner,
+			&link.OriginalURL,
+			&created,
+			&expires,
+			&link.TotalClicks,
+			&link.UniqueVisitors,
+		); err != nil {
+			return storage.LinkPage{}, err
+		}
+		link.OwnerID = owner.Int64
+		if link.CreatedAt, err = parseTime(created); err != nil {
+			return storage.LinkPage{}, err
+		}
+		if link.ExpiresAt, err = parseTime(expires); err != nil {
+			return storage.LinkPage{}, err
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.LinkPage{}, err
+	}
+
+	page := storage.LinkPage{Links: links}
+	if opts.Limit > 0 && len(links) > opts.Limit {
+		page.Links = links[:opts.Limit]
+		page.NextCursor = encodeCursor(sortField(opts), page.Links[opts.Limit-1])
+	}
+	return page, nil
+}
+
+func buildListQuery(opts storage.ListOptions, now time.Time) (string, []any, error) {
+	var filters []string
+	var args []any
+	if opts.OwnerID != 0 {
+		filters = append(filters, "l.owner_id = ?")
+		args = append(args, opts.OwnerID)
+	}
+	if opts.Domain != nil {
+		filters = append(filters, "l.domain = ?")
+		args = append(args, *opts.Domain)
+	}
+	if !opts.CreatedAfter.IsZero() {
+		filters = append(filters, "l.created_at >= ?")
+		args = append(args, formatTime(opts.CreatedAfter))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		filters = append(filters, "l.created_at < ?")
+		args = append(args, formatTime(opts.CreatedBefore))
+	}
+	if !opts.ExpiresAfter.IsZero() {
+		filters = append(filters, "l.expires_at >= ?")
+		args = append(args, formatTime(opts.ExpiresAfter))
+	}
+	if !opts.ExpiresBefore.IsZero() {
+		filters = append(filters, "l.expires_at < ?")
+		args = append(args, formatTime(opts.ExpiresBefore))
+	}
+	switch opts.Status {
+	case storage.StatusAny:
+	case storage.StatusActive:
+		filters = append(filters, "l.expires_at > ?")
+		args = append(args, formatTime(now))
+	case storage.StatusExpired:
+		filters = append(filters, "l.expires_at <= ?")
+		args = append(args, formatTime(now))
+	default:
+		return "", nil, fmt.Errorf("unknown link status %q", opts.Status)
+	}
+	if search := strings.TrimSpace(opts.Search); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		filters = append(filters, `(l.code LIKE ? ESCAPE '\' OR l.original_url LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	sort := sortField(opts)
+	column, ok := sortColumns[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort field %q", sort)
+	}
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	var outer []string
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor, sort)
+		if err != nil {
+			return "", nil, err
+		}
+		outer = append(outer, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND (code, domain) %[2]s (?, ?)))", column, comparison))
+		args = append(args, cursor.value, cursor.value, cursor.code, cursor.domain)
+	}
+
+	// Human totals are kept on the link by triggers; with bots they are
+	// counted per link.
+	totals := `l.click_count AS total_clicks, l.visitor_count AS unique_visitors`
+	if opts.IncludeBots {
+		// unique_ips only holds human visitors, so bots are counted from clicks.
+		totals = `(SELECT COUNT(*) FROM clicks c WHERE c.domain = l.domain AND c.code = l.code) AS total_clicks,
+				(SELECT COUNT(DISTINCT c.visitor) FROM clicks c WHERE c.domain = l.domain AND c.code = l.code AND c.visitor <> '') AS unique_visitors`
+	}
+
+	var query strings.Builder
+	query.WriteString(`SELECT domain, code, owner_id, original_url, created_at, expires_at, total_clicks, unique_visitors
+		FROM (
+			SELECT l.domain, l.code, l.owner_id, l.original_url, l.created_at, l.expires_at,
+				` + totals + `
+			FROM links l`)
+	if len(filters) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(filters, " AND "))
+	}
+	query.WriteString(")")
+	if len(outer) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(outer, " AND "))
+	}
+	fmt.Fprintf(&query, " ORDER BY %[1]s %[2]s, code %[2]s, domain %[2]s", column, direction)
+	if opts.Limit > 0 {
+		query.WriteString(" LIMIT ?")
+		args = append(args, opts.Limit+1)
+	}
+	return query.String(), args, nil
+}
+
+func sortField(opts storage.ListOptions) storage.SortField {
+	if opts.SortBy == "" {
+		return storage.SortCreated
+	}
+	return opts.SortBy
+}
+
+type decodedCursor struct {
+	value  any
+	code   string
+	domain string
+}
+
+func encodeCursor(sort storage.SortField, link model.LinkSummary) string {
+	cursor := listCursor{Sort: sort, Code: link.Code, Domain: link.Domain}
+	switch sort {
+	case storage.SortCreated:
+		cursor.Value = formatTime(link.CreatedAt)
+	case storage.SortExpires:
+		cursor.Value = formatTime(link.ExpiresAt)
+	case storage.SortClicks:
+		cursor.Value = strconv.Itoa(link.TotalClicks)
+	case storage.SortUniqueVisitors:
+		cursor.Value = strconv.Itoa(link.UniqueVisitors)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, sort storage.SortField) (decodedCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return decodedCursor{}, storage.ErrInvalidCursor
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.Code == "" {
+		return decodedCursor{}, storage.ErrInvalidCursor
+	}
+	switch sort {
+	case storage.SortClicks, storage.SortUniqueVisitors:
+		n, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return decodedCursor{}, storage.ErrInvalidCursor
+		}
+		return decodedCursor{value: n, code: cursor.Code, domain: cursor.Domain}, nil
+	default:
+		t, err := parseTime(cursor.Value)
+		if err != nil {
+			return decodedCursor{}, storage.ErrInvalidCursor
+		}
+		return decodedCursor{value: formatTime(t), code: cursor.Code, domain: cursor.Domain}, nil
+	}
+}
+
+// botFilter is the condition on clicks c that leaves bots out, or nothing
+// when they are included.
+func botFilter(includeBots bool) string {
+	if includeBots {
+		return ""
+	}
+	return " AND c.is_bot = 0"
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
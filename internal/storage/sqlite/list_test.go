@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// seedLinks stores n links in one transaction and gives link i i%10 clicks,
+// each from its own IP.
+func seedLinks(b *testing.B, s *Store, n int) {
+	b.Helper()
+	ctx := b.Context()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tx.Rollback()
+	for i := 0; i < n; i++ {
+		link := &model.Link{
+			Code:        fmt.Sprintf("link%06d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			CreatedAt:   start.Add(time.Duration(i) * time.Second),
+			ExpiresAt:   start.Add(365 * 24 * time.Hour),
+		}
+		if err := insertLink(ctx, tx, link); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+
+	var events []storage.ClickEvent
+	for i := 0; i < n; i++ {
+		for c := 0; c < i%10; c++ {
+			events = append(events, storage.ClickEvent{
+				Code: fmt.Sprintf("link%06d", i),
+				Click: model.Click{
+					Timestamp: start.Add(time.Duration(i+c) * time.Second),
+					IP:        fmt.Sprintf("10.%d.%d.%d", c, i/256%256, i%256),
+				},
+			})
+		}
+	}
+	if err := s.RecordClicks(ctx, events); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// listPerLink is how List worked before it aggregated in SQL: one query for
+// the links, then a click count and the unique visitors for each link. It is
+// the baseline BenchmarkList compares against.
+func listPerLink(b *testing.B, s *Store) map[string][2]int {
+	ctx := b.Context()
+	rows, err := s.db.QueryContext(ctx, `SELECT domain, code FROM links ORDER BY created_at DESC`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	type key struct{ domain, code string }
+	var keys []key
+	for rows.Next() {
+		var k key
+		if err := rows.Scan(&k.domain, &k.code); err != nil {
+			b.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+
+	totals := make(map[string][2]int, len(keys))
+	for _, k := range keys {
+		var clicks int
+		if err := s.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM clicks WHERE domain = ? AND code = ? AND is_bot = 0`, k.domain, k.code,
+		).Scan(&clicks); err != nil {
+			b.Fatal(err)
+		}
+		visitors, err := s.db.QueryContext(ctx, `SELECT visitor FROM unique_ips WHERE domain = ? AND code = ?`, k.domain, k.code)
+		if err != nil {
+			b.Fatal(err)
+		}
+		unique := make(map[string]struct{})
+		for visitors.Next() {
+			var v string
+			if err := visitors.Scan(&v); err != nil {
+				b.Fatal(err)
+			}
+			unique[v] = struct{}{}
+		}
+		visitors.Close()
+		totals[k.code] = [2]int{clicks, len(unique)}
+	}
+	return totals
+}
+
+// BenchmarkList lists 20,000 links with about 90,000 clicks. "all" lists
+// every link, as the original per-link List did, against listPerLink; "page"
+// lists 50 links in each sort order. Every listing is checked against the
+// baseline's totals first.
+func BenchmarkList(b *testing.B) {
+	s := newTestStore(b)
+	seedLinks(b, s, 20000)
+
+	want := listPerLink(b, s)
+	all, err := s.List(b.Context(), storage.ListOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(all.Links) != len(want) {
+		b.Fatalf("listed %d links, want %d", len(all.Links), len(want))
+	}
+	for _, link := range all.Links {
+		if got := [2]int{link.TotalClicks, link.UniqueVisitors}; got != want[link.Code] {
+			b.Fatalf("%s: clicks and visitors = %v, want %v", link.Code, got, want[link.Code])
+		}
+	}
+
+	b.Run("all/per-link", func(b *testing.B) {
+		for b.Loop() {
+			listPerLink(b, s)
+		}
+	})
+	b.Run("all/list", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := s.List(b.Context(), storage.ListOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, sort := range []storage.SortField{storage.SortCreated, storage.SortClicks, storage.SortUniqueVisitors} {
+		b.Run("page/"+string(sort), func(b *testing.B) {
+			opts := storage.ListOptions{Limit: 50, SortBy: sort, Descending: true}
+			for b.Loop() {
+				page, err := s.List(b.Context(), opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(page.Links) != opts.Limit {
+					b.Fatalf("page has %d links, want %d", len(page.Links), opts.Limit)
+				}
+				if top := want[page.Links[0].Code]; sort != storage.SortCreated && top != [2]int{9, 9} {
+					b.Fatalf("top link has %v clicks and visitors, want the maximum [9 9]", top)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-shortener/internal/storage"
+)
+
+// Migrations are the files in migrations/, named NNNN_description.sql and
+// applied in order of NNNN. Applied migrations must never be edited; add a new
+// file instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []migration
+	for _, entry := range entries {
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_description.sql", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate applies every pending migration, each in its own transaction, and
+// returns the ones it applied.
+func migrate(db *sql.DB) ([]storage.Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	);`); err != nil {
+		return nil, err
+	}
+
+	var applied []storage.Migration
+	for _, m := range migrations {
+		ok, err := applyMigration(db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+		}
+		if ok {
+			applied = append(applied, storage.Migration{Version: m.version, Name: m.name, AppliedAt: time.Now().UTC()})
+		}
+	}
+	return applied, nil
+}
+
+func applyMigration(db *sql.DB, m migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Checked inside the transaction so concurrent starts apply it once.
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&n); err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, nil
+	}
+
+	if m.version == 1 {
+		legacy, err := tableExists(tx, "links")
+		if err != nil {
+			return false, err
+		}
+		if legacy {
+			if err := upgradeLegacySchema(tx); err != nil {
+				return false, err
+			}
+		}
+	}
+	if _, err := tx.Exec(m.sql); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version,
+		m.name,
+		formatTime(time.Now()),
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MigrationStatus reports every known migration for the database at path
+// without changing it.
+func MigrationStatus(path string) ([]storage.Migration, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	exists, err := tableExists(db, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			var at string
+			if err := rows.Scan(&version, &at); err != nil {
+				return nil, err
+			}
+			if applied[version], err = parseTime(at); err != nil {
+				return nil, err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]storage.Migration, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, storage.Migration{Version: m.version, Name: m.name, AppliedAt: applied[m.version]})
+	}
+	return status, nil
+}
+
+// Migrate applies pending migrations to the database at path and returns the
+// ones it applied. New does the same on every start.
+func Migrate(path string) ([]storage.Migration, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return migrate(db)
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// TestRestoreUnknownAgentClicks replays what the first version of migration 5
+// did to a click without a user agent, and checks that migration 11 counts it
+// again while a click recorded later keeps its flag.
+func TestRestoreUnknownAgentClicks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	ctx := t.Context()
+
+	now := time.Now()
+	link := &model.Link{Code: "launch", OriginalURL: "https://example.com", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(time.Hour)}
+	if err := s.Save(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	clicks := []model.Click{
+		{Timestamp: now.Add(-time.Hour), IP: "203.0.113.7", Device: "unknown"},
+		{Timestamp: now.Add(time.Minute), IP: "203.0.113.8", Device: "unknown", IsBot: true},
+	}
+	for _, c := range clicks {
+		if err := s.RecordClick(ctx, "", "launch", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, query := range []string{
+		`UPDATE clicks SET is_bot = 1 WHERE device = 'unknown'`,
+		`DELETE FROM unique_ips`,
+		`DELETE FROM schema_migrations WHERE version = 11`,
+	} {
+		if _, err := s.db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if applied, err := Migrate(path); err != nil || len(applied) != 1 || applied[0].Version != 11 {
+		t.Fatalf("Migrate = %v, %v; want migration 11", applied, err)
+	}
+	got, err := s.Get(ctx, "", "launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Clicks[0].IsBot || !got.Clicks[1].IsBot {
+		t.Fatalf("bot flags = %v, %v; want false, true", got.Clicks[0].IsBot, got.Clicks[1].IsBot)
+	}
+	if _, ok := got.UniqueIPs["203.0.113.7"]; !ok || len(got.UniqueIPs) != 1 {
+		t.Fatalf("unique visitors = %v, want [203.0.113.7]", got.UniqueIPs)
+	}
+	page, err := s.List(ctx, storage.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := page.Links[0]; l.TotalClicks != 1 || l.UniqueVisitors != 1 {
+		t.Fatalf("listed %d clicks from %d visitors, want 1 and 1", l.TotalClicks, l.UniqueVisitors)
+	}
+}
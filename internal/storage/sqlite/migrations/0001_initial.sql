@@ -0,0 +1,77 @@
+-- Baseline schema. IF NOT EXISTS lets databases created before migrations
+-- existed adopt it once their ad-hoc upgrades have run (see legacy.go).
+
+CREATE TABLE IF NOT EXISTS workspaces (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS domains (
+	domain TEXT PRIMARY KEY,
+	workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	role TEXT NOT NULL,
+	workspace_id INTEGER REFERENCES workspaces(id),
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS links (
+	domain TEXT NOT NULL DEFAULT '',
+	code TEXT NOT NULL,
+	owner_id INTEGER REFERENCES users(id),
+	original_url TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL,
+	PRIMARY KEY (domain, code)
+);
+
+CREATE TABLE IF NOT EXISTS clicks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT NOT NULL DEFAULT '',
+	code TEXT NOT NULL,
+	timestamp TEXT NOT NULL,
+	ip TEXT,
+	country TEXT,
+	user_agent TEXT,
+	FOREIGN KEY(domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS unique_ips (
+	domain TEXT NOT NULL DEFAULT '',
+	code TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	PRIMARY KEY (domain, code, ip),
+	FOREIGN KEY(domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS geo_cache (
+	prefix TEXT PRIMARY KEY,
+	country TEXT NOT NULL,
+	region TEXT NOT NULL DEFAULT '',
+	city TEXT NOT NULL DEFAULT '',
+	asn INTEGER NOT NULL DEFAULT 0,
+	as_org TEXT NOT NULL DEFAULT '',
+	expires_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_clicks_link_timestamp ON clicks(domain, code, timestamp);
+CREATE INDEX IF NOT EXISTS idx_links_created_at ON links(created_at);
+CREATE INDEX IF NOT EXISTS idx_links_expires_at ON links(expires_at);
+CREATE INDEX IF NOT EXISTS idx_links_owner_id ON links(owner_id);
+CREATE INDEX IF NOT EXISTS idx_domains_workspace_id ON domains(workspace_id);
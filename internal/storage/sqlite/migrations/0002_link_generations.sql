@@ -0,0 +1,25 @@
+-- Links whose code expired and was claimed again are archived here with their
+-- clicks instead of being overwritten.
+
+CREATE TABLE link_generations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT NOT NULL DEFAULT '',
+	code TEXT NOT NULL,
+	owner_id INTEGER REFERENCES users(id),
+	original_url TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL,
+	archived_at TEXT NOT NULL
+);
+
+CREATE TABLE generation_clicks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	generation_id INTEGER NOT NULL REFERENCES link_generations(id) ON DELETE CASCADE,
+	timestamp TEXT NOT NULL,
+	ip TEXT,
+	country TEXT,
+	user_agent TEXT
+);
+
+CREATE INDEX idx_link_generations_link ON link_generations(domain, code);
+CREATE INDEX idx_generation_clicks_generation ON generation_clicks(generation_id, timestamp);
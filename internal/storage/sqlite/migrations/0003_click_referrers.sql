@@ -0,0 +1,4 @@
+-- Normalised referrer (host and path) of each click; empty means direct.
+
+ALTER TABLE clicks ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN referrer TEXT NOT NULL DEFAULT '';
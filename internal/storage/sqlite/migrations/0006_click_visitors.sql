@@ -0,0 +1,10 @@
+-- Unique visitors are identified by a visitor key instead of the raw IP, so
+-- privacy mode can store truncated IPs and a daily hash. Existing clicks keep
+-- their IP as the key.
+
+ALTER TABLE clicks ADD COLUMN visitor TEXT NOT NULL DEFAULT '';
+ALTER TABLE generation_clicks ADD COLUMN visitor TEXT NOT NULL DEFAULT '';
+UPDATE clicks SET visitor = ip WHERE ip IS NOT NULL;
+UPDATE generation_clicks SET visitor = ip WHERE ip IS NOT NULL;
+
+ALTER TABLE unique_ips RENAME COLUMN ip TO visitor;
@@ -0,0 +1,9 @@
+-- Listing orders by created_at or expires_at with code and domain as
+-- tie-breakers. Indexes in that exact order let a page be read straight off
+-- the index, so click totals are only counted for the rows on the page
+-- instead of every link before sorting.
+
+DROP INDEX idx_links_created_at;
+DROP INDEX idx_links_expires_at;
+CREATE INDEX idx_links_created_at ON links(created_at, code, domain);
+CREATE INDEX idx_links_expires_at ON links(expires_at, code, domain);
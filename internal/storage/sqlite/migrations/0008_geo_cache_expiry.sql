@@ -0,0 +1,4 @@
+-- SaveLocation periodically deletes expired geo_cache rows; this index keeps
+-- that from scanning the whole table.
+
+CREATE INDEX idx_geo_cache_expires_at ON geo_cache(expires_at);
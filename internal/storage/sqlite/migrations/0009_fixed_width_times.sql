@@ -0,0 +1,23 @@
+-- Timestamps are stored in a fixed-width layout (timeLayout in sqlite.go) so
+-- they compare as text. Databases from before that wrote RFC 3339 with only as
+-- many fractional digits as needed, e.g. 2025-01-02T03:04:05.5Z, which sorts
+-- after 2025-01-02T03:04:05.123Z. Those rows only exist in the tables the
+-- original schema had, and always in UTC; pad them to nine digits.
+
+UPDATE links SET created_at = CASE length(created_at)
+	WHEN 20 THEN substr(created_at, 1, 19) || '.000000000Z'
+	ELSE substr(created_at, 1, length(created_at) - 1) || substr('00000000', 1, 30 - length(created_at)) || 'Z'
+END
+WHERE length(created_at) < 30 AND created_at LIKE '%Z';
+
+UPDATE links SET expires_at = CASE length(expires_at)
+	WHEN 20 THEN substr(expires_at, 1, 19) || '.000000000Z'
+	ELSE substr(expires_at, 1, length(expires_at) - 1) || substr('00000000', 1, 30 - length(expires_at)) || 'Z'
+END
+WHERE length(expires_at) < 30 AND expires_at LIKE '%Z';
+
+UPDATE clicks SET timestamp = CASE length(timestamp)
+	WHEN 20 THEN substr(timestamp, 1, 19) || '.000000000Z'
+	ELSE substr(timestamp, 1, length(timestamp) - 1) || substr('00000000', 1, 30 - length(timestamp)) || 'Z'
+END
+WHERE length(timestamp) < 30 AND timestamp LIKE '%Z';
@@ -0,0 +1,42 @@
+-- Listing sorted by clicks or unique visitors had to count every link's
+-- clicks before it could pick a page. Links now carry their human click and
+-- unique visitor totals, kept up to date by triggers, and indexes in listing
+-- order so such a page is read straight off the index. The counts belong to
+-- the link row: renameLink copies them, so moving clicks between codes does
+-- not touch them.
+
+ALTER TABLE links ADD COLUMN click_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN visitor_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE links SET
+	click_count = (SELECT COUNT(*) FROM clicks c WHERE c.domain = links.domain AND c.code = links.code AND c.is_bot = 0),
+	visitor_count = (SELECT COUNT(*) FROM unique_ips u WHERE u.domain = links.domain AND u.code = links.code);
+
+CREATE INDEX idx_links_click_count ON links(click_count, code, domain);
+CREATE INDEX idx_links_visitor_count ON links(visitor_count, code, domain);
+
+CREATE TRIGGER clicks_count_insert AFTER INSERT ON clicks WHEN NEW.is_bot = 0
+BEGIN
+	UPDATE links SET click_count = click_count + 1 WHERE domain = NEW.domain AND code = NEW.code;
+END;
+
+CREATE TRIGGER clicks_count_delete AFTER DELETE ON clicks WHEN OLD.is_bot = 0
+BEGIN
+	UPDATE links SET click_count = click_count - 1 WHERE domain = OLD.domain AND code = OLD.code;
+END;
+
+CREATE TRIGGER clicks_count_is_bot AFTER UPDATE OF is_bot ON clicks WHEN OLD.is_bot <> NEW.is_bot
+BEGIN
+	UPDATE links SET click_count = click_count + CASE NEW.is_bot WHEN 0 THEN 1 ELSE -1 END
+	WHERE domain = NEW.domain AND code = NEW.code;
+END;
+
+CREATE TRIGGER unique_ips_count_insert AFTER INSERT ON unique_ips
+BEGIN
+	UPDATE links SET visitor_count = visitor_count + 1 WHERE domain = NEW.domain AND code = NEW.code;
+END;
+
+CREATE TRIGGER unique_ips_count_delete AFTER DELETE ON unique_ips
+BEGIN
+	UPDATE links SET visitor_count = visitor_count - 1 WHERE domain = OLD.domain AND code = OLD.code;
+END;
@@ -0,0 +1,15 @@
+-- An earlier version of migration 5 also flagged clicks without a user agent
+-- as bots and deleted the unique visitors that only such clicks had made.
+-- Those clicks are counted again and their visitors restored from the clicks.
+-- Clicks recorded since then without a user agent stay flagged: they cannot
+-- be told apart from HEAD requests and BOT_IP_RANGES hits.
+
+UPDATE clicks SET is_bot = 0
+WHERE is_bot = 1 AND device = 'unknown'
+  AND timestamp <= (SELECT applied_at FROM schema_migrations WHERE version = 5);
+UPDATE generation_clicks SET is_bot = 0
+WHERE is_bot = 1 AND device = 'unknown'
+  AND timestamp <= (SELECT applied_at FROM schema_migrations WHERE version = 5);
+
+INSERT OR IGNORE INTO unique_ips (domain, code, visitor)
+SELECT DISTINCT domain, code, visitor FROM clicks WHERE is_bot = 0 AND visitor <> '';
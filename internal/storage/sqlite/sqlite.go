@@ -1,10 +1,12 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -15,66 +17,68 @@ import (
 
 type Store struct {
 	db *sql.DB
+
+	geoSaves atomic.Uint64
 }
 
+// New opens the database at path and applies any pending migrations.
 func New(path string) (*Store, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func open(path string) (*sql.DB, error) {
 	dbPath := strings.TrimSpace(path)
 	if dbPath == "" {
 		dbPath = "data.db"
 	}
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn(dbPath))
 	if err != nil {
 		return nil, err
 	}
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
-	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
-		return nil, err
-	}
-	if err := ensureSchema(db); err != nil {
-		return nil, err
-	}
-	return &Store{db: db}, nil
+	return db, nil
 }
 
-func ensureSchema(db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS links (
-			code TEXT PRIMARY KEY,
-			original_url TEXT NOT NULL,
-			created_at TEXT NOT NULL,
-			expires_at TEXT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS clicks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			code TEXT NOT NULL,
-			timestamp TEXT NOT NULL,
-			ip TEXT,
-			country TEXT,
-			user_agent TEXT,
-			FOREIGN KEY(code) REFERENCES links(code) ON DELETE CASCADE
-		);`,
-		`CREATE TABLE IF NOT EXISTS unique_ips (
-			code TEXT NOT NULL,
-			ip TEXT NOT NULL,
-			PRIMARY KEY (code, ip),
-			FOREIGN KEY(code) REFERENCES links(code) ON DELETE CASCADE
-		);`,
-	}
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return err
-		}
-	}
-	return nil
+// dsn applies the connection pragmas to every pooled connection rather than
+// only the one that happens to run a PRAGMA statement. Transactions begin
+// IMMEDIATE: a deferred transaction that reads before writing gets SQLITE_BUSY
+// straight away when another writer holds the lock, instead of waiting out
+// busy_timeout. WAL lets readers carry on while a writer commits, so
+// concurrent redirects and analytics reads do not starve the click writer.
+func dsn(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+}
+
+func (s *Store) Save(ctx context.Context, link *model.Link) error {
+	return insertLink(ctx, s.db, link)
 }
 
-func (s *Store) Save(link *model.Link) error {
-	_, err := s.db.Exec(
-		`INSERT INTO links (code, original_url, created_at, expires_at)
-		 VALUES (?, ?, ?, ?)`,
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertLink(ctx context.Context, db execer, link *model.Link) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO links (domain, code, owner_id, original_url, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		link.Domain,
 		link.Code,
+		nullID(link.OwnerID),
 		link.OriginalURL,
 		formatTime(link.CreatedAt),
 		formatTime(link.ExpiresAt),
@@ -88,155 +92,271 @@ func (s *Store) Save(link *model.Link) error {
 	return nil
 }
 
-func (s *Store) Upsert(link *model.Link) error {
-	tx, err := s.db.Begin()
+func (s *Store) ReplaceExpired(ctx context.Context, link *model.Link) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(`DELETE FROM clicks WHERE code = ?`, link.Code); err != nil {
+	var expires string
+	err = tx.QueryRowContext(ctx,
+		`SELECT expires_at FROM links WHERE domain = ? AND code = ?`,
+		link.Domain,
+		link.Code,
+	).Scan(&expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := insertLink(ctx, tx, link); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM unique_ips WHERE code = ?`, link.Code); err != nil {
+	expiresAt, err := parseTime(expires)
+	if err != nil {
 		return err
 	}
+	if !expiresAt.Before(link.CreatedAt) {
+		return storage.ErrCodeExists
+	}
 
-	_, err = tx.Exec(
-		`INSERT INTO links (code, original_url, created_at, expires_at)
-		 VALUES (?, ?, ?, ?)
-		 ON CONFLICT(code) DO UPDATE SET
-		   original_url = excluded.original_url,
-		   created_at = excluded.created_at,
-		   expires_at = excluded.expires_at`,
-		link.Code,
+	if err := archiveLink(ctx, tx, link.Domain, link.Code, link.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE links SET owner_id = ?, original_url = ?, created_at = ?, expires_at = ?
+		 WHERE domain = ? AND code = ?`,
+		nullID(link.OwnerID),
 		link.OriginalURL,
 		formatTime(link.CreatedAt),
 		formatTime(link.ExpiresAt),
-	)
-	if err != nil {
+		link.Domain,
+		link.Code,
+	); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
 
-func (s *Store) Get(code string) (*model.Link, bool) {
-	row := s.db.QueryRow(
-		`SELECT code, original_url, created_at, expires_at
-		 FROM links WHERE code = ?`,
+func (s *Store) Get(ctx context.Context, domain, code string) (*model.Link, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT domain, code, owner_id, original_url, created_at, expires_at
+		 FROM links WHERE domain = ? AND code = ?`,
+		domain,
 		code,
 	)
 
 	var link model.Link
+	var owner sql.NullInt64
 	var created, expires string
-	if err := row.Scan(&link.Code, &link.OriginalURL, &created, &expires); err != nil {
+	if err := row.Scan(&link.Domain, &link.Code, &owner, &link.OriginalURL, &created, &expires); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, false
+			return nil, storage.ErrNotFound
 		}
-		return nil, false
+		return nil, err
 	}
 
 	createdAt, err := parseTime(created)
 	if err != nil {
-		return nil, false
+		return nil, err
 	}
 	expiresAt, err := parseTime(expires)
 	if err != nil {
-		return nil, false
+		return nil, err
 	}
+	link.OwnerID = owner.Int64
 	link.CreatedAt = createdAt
 	link.ExpiresAt = expiresAt
 
-	clicks, err := s.loadClicks(code)
+	if link.Clicks, err = s.loadClicks(ctx, domain, code); err != nil {
+		return nil, err
+	}
+	if link.UniqueIPs, err = s.loadUniqueIPs(ctx, domain, code); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *Store) Resolve(ctx context.Context, domain, code string) (*model.LinkTarget, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT domain, code, owner_id, original_url, expires_at
+		 FROM links WHERE domain = ? AND code = ?`,
+		domain,
+		code,
+	)
+
+	var target model.LinkTarget
+	var owner sql.NullInt64
+	var expires string
+	if err := row.Scan(&target.Domain, &target.Code, &owner, &target.OriginalURL, &expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	target.OwnerID = owner.Int64
+	expiresAt, err := parseTime(expires)
 	if err != nil {
-		return nil, false
+		return nil, err
 	}
-	link.Clicks = clicks
+	target.ExpiresAt = expiresAt
+	return &target, nil
+}
 
-	uniqueIPs, err := s.loadUniqueIPs(code)
+func (s *Store) RecordClick(ctx context.Context, domain, code string, click model.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, false
+		return err
 	}
-	link.UniqueIPs = uniqueIPs
+	defer tx.Rollback()
 
-	return &link, true
-}
+	var exists int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT 1 FROM links WHERE domain = ? AND code = ?`,
+		domain,
+		code,
+	).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
 
-func (s *Store) List() []*model.Link {
-	rows, err := s.db.Query(
-		`SELECT code, original_url, created_at, expires_at
-		 FROM links ORDER BY created_at DESC`,
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO clicks (domain, code, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		domain,
+		code,
+		formatTime(click.Timestamp),
+		click.IP,
+		click.Country,
+		click.UserAgent,
+		click.Referrer,
+		click.Browser,
+		click.BrowserVersion,
+		click.OS,
+		click.Device,
+		click.IsBot,
+		click.VisitorKey(),
 	)
 	if err != nil {
-		return nil
+		return err
 	}
-	defer rows.Close()
 
-	var links []*model.Link
-	for rows.Next() {
-		var link model.Link
-		var created, expires string
-		if err := rows.Scan(&link.Code, &link.OriginalURL, &created, &expires); err != nil {
-			continue
+	if click.VisitorKey() != "" && !click.IsBot {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO unique_ips (domain, code, visitor) VALUES (?, ?, ?)`,
+			domain,
+			code,
+			click.VisitorKey(),
+		); err != nil {
+			return err
 		}
-		createdAt, err := parseTime(created)
-		if err != nil {
-			continue
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RecordClicks(ctx context.Context, events []storage.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertClick, err := tx.PrepareContext(ctx,
+		`INSERT INTO clicks (domain, code, timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor)
+		 SELECT ?1, ?2, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		 WHERE EXISTS (SELECT 1 FROM links WHERE domain = ?1 AND code = ?2)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insertClick.Close()
+
+	insertVisitor, err := tx.PrepareContext(ctx,
+		`INSERT OR IGNORE INTO unique_ips (domain, code, visitor)
+		 SELECT ?1, ?2, ?3
+		 WHERE EXISTS (SELECT 1 FROM links WHERE domain = ?1 AND code = ?2)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insertVisitor.Close()
+
+	for _, event := range events {
+		click := event.Click
+		if _, err := insertClick.ExecContext(ctx,
+			event.Domain,
+			event.Code,
+			formatTime(click.Timestamp),
+			click.IP,
+			click.Country,
+			click.UserAgent,
+			click.Referrer,
+			click.Browser,
+			click.BrowserVersion,
+			click.OS,
+			click.Device,
+			click.IsBot,
+			click.VisitorKey(),
+		); err != nil {
+			return err
 		}
-		expiresAt, err := parseTime(expires)
-		if err != nil {
+		if click.VisitorKey() == "" || click.IsBot {
 			continue
 		}
-		link.CreatedAt = createdAt
-		link.ExpiresAt = expiresAt
-
-		totalClicks, err := s.loadClickCount(link.Code)
-		if err == nil {
-			link.Clicks = make([]model.Click, totalClicks)
-		}
-		uniqueIPs, err := s.loadUniqueIPs(link.Code)
-		if err == nil {
-			link.UniqueIPs = uniqueIPs
+		if _, err := insertVisitor.ExecContext(ctx, event.Domain, event.Code, click.VisitorKey()); err != nil {
+			return err
 		}
-		links = append(links, &link)
 	}
-	return links
+	return tx.Commit()
 }
 
-func (s *Store) RecordClick(code string, click model.Click) (*model.Link, error) {
-	tx, err := s.db.Begin()
+func (s *Store) Update(ctx context.Context, domain, code string, update storage.LinkUpdate) (*model.Link, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	var exists int
-	if err := tx.QueryRow(`SELECT 1 FROM links WHERE code = ?`, code).Scan(&exists); err != nil {
+	var originalURL, expires string
+	if err := tx.QueryRowContext(ctx,
+		`SELECT original_url, expires_at FROM links WHERE domain = ? AND code = ?`,
+		domain,
+		code,
+	).Scan(&originalURL, &expires); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrNotFound
 		}
 		return nil, err
 	}
 
-	_, err = tx.Exec(
-		`INSERT INTO clicks (code, timestamp, ip, country, user_agent)
-		 VALUES (?, ?, ?, ?, ?)`,
+	if update.OriginalURL != nil {
+		originalURL = *update.OriginalURL
+	}
+	if update.ExpiresAt != nil {
+		expires = formatTime(*update.ExpiresAt)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE links SET original_url = ?, expires_at = ? WHERE domain = ? AND code = ?`,
+		originalURL,
+		expires,
+		domain,
 		code,
-		formatTime(click.Timestamp),
-		click.IP,
-		click.Country,
-		click.UserAgent,
-	)
-	if err != nil {
+	); err != nil {
 		return nil, err
 	}
 
-	if click.IP != "" {
-		if _, err := tx.Exec(
-			`INSERT OR IGNORE INTO unique_ips (code, ip) VALUES (?, ?)`,
-			code,
-			click.IP,
-		); err != nil {
+	newCode := code
+	if update.Code != nil && *update.Code != code {
+		newCode = *update.Code
+		if err := renameLink(ctx, tx, domain, code, newCode); err != nil {
 			return nil, err
 		}
 	}
@@ -244,17 +364,70 @@ func (s *Store) RecordClick(code string, click model.Click) (*model.Link, error)
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	link, ok := s.Get(code)
-	if !ok {
-		return nil, storage.ErrNotFound
+	return s.Get(ctx, domain, newCode)
+}
+
+func renameLink(ctx context.Context, tx *sql.Tx, domain, oldCode, newCode string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO links (domain, code, owner_id, original_url, created_at, expires_at, click_count, visitor_count)
+		 SELECT domain, ?, owner_id, original_url, created_at, expires_at, click_count, visitor_count
+		 FROM links WHERE domain = ? AND code = ?`,
+		newCode,
+		domain,
+		oldCode,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrCodeExists
+		}
+		return err
+	}
+	for _, table := range []string{"clicks", "unique_ips"} {
+		if _, err := tx.ExecContext(ctx,
+			fmt.Sprintf(`UPDATE %s SET code = ? WHERE domain = ? AND code = ?`, table),
+			newCode,
+			domain,
+			oldCode,
+		); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM links WHERE domain = ? AND code = ?`, domain, oldCode)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, domain, code string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clicks WHERE domain = ? AND code = ?`, domain, code); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM unique_ips WHERE domain = ? AND code = ?`, domain, code); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM links WHERE domain = ? AND code = ?`, domain, code)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
-	return link, nil
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+	return tx.Commit()
 }
 
-func (s *Store) loadClicks(code string) ([]model.Click, error) {
-	rows, err := s.db.Query(
-		`SELECT timestamp, ip, country, user_agent
-		 FROM clicks WHERE code = ? ORDER BY timestamp`,
+func (s *Store) loadClicks(ctx context.Context, domain, code string) ([]model.Click, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT timestamp, ip, country, user_agent, referrer, browser, browser_version, os, device, is_bot, visitor
+		 FROM clicks WHERE domain = ? AND code = ? ORDER BY timestamp`,
+		domain,
 		code,
 	)
 	if err != nil {
@@ -266,7 +439,19 @@ func (s *Store) loadClicks(code string) ([]model.Click, error) {
 	for rows.Next() {
 		var click model.Click
 		var timestamp string
-		if err := rows.Scan(&timestamp, &click.IP, &click.Country, &click.UserAgent); err != nil {
+		if err := rows.Scan(
+			&timestamp,
+			&click.IP,
+			&click.Country,
+			&click.UserAgent,
+			&click.Referrer,
+			&click.Browser,
+			&click.BrowserVersion,
+			&click.OS,
+			&click.Device,
+			&click.IsBot,
+			&click.Visitor,
+		); err != nil {
 			return nil, err
 		}
 		parsed, err := parseTime(timestamp)
@@ -276,11 +461,11 @@ func (s *Store) loadClicks(code string) ([]model.Click, error) {
 		click.Timestamp = parsed
 		clicks = append(clicks, click)
 	}
-	return clicks, nil
+	return clicks, rows.Err()
 }
 
-func (s *Store) loadUniqueIPs(code string) (map[string]struct{}, error) {
-	rows, err := s.db.Query(`SELECT ip FROM unique_ips WHERE code = ?`, code)
+func (s *Store) loadUniqueIPs(ctx context.Context, domain, code string) (map[string]struct{}, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT visitor FROM unique_ips WHERE domain = ? AND code = ?`, domain, code)
 	if err != nil {
 		return nil, err
 	}
@@ -288,28 +473,22 @@ func (s *Store) loadUniqueIPs(code string) (map[string]struct{}, error) {
 
 	unique := make(map[string]struct{})
 	for rows.Next() {
-		var ip string
-		if err := rows.Scan(&ip); err != nil {
+		var visitor string
+		if err := rows.Scan(&visitor); err != nil {
 			return nil, err
 		}
-		if ip != "" {
-			unique[ip] = struct{}{}
+		if visitor != "" {
+			unique[visitor] = struct{}{}
 		}
 	}
-	return unique, nil
+	return unique, rows.Err()
 }
 
-func (s *Store) loadClickCount(code string) (int, error) {
-	row := s.db.QueryRow(`SELECT COUNT(*) FROM clicks WHERE code = ?`, code)
-	var count int
-	if err := row.Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
+// timeLayout is fixed-width so stored timestamps sort and compare as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
 
 func formatTime(value time.Time) string {
-	return value.UTC().Format(time.RFC3339Nano)
+	return value.UTC().Format(timeLayout)
 }
 
 func parseTime(value string) (time.Time, error) {
@@ -323,6 +502,10 @@ func parseTime(value string) (time.Time, error) {
 	return time.Parse(time.RFC3339, value)
 }
 
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
 func isUniqueViolation(err error) bool {
 	if err == nil {
 		return false
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+	"link-shortener/internal/storage/storagetest"
+)
+
+func newTestStore(t testing.TB) *Store {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store { return newTestStore(t) })
+}
+
+// BenchmarkResolve redirects a code with a growing click history. Resolve
+// reads only the link row, so the time per lookup should stay flat.
+func BenchmarkResolve(b *testing.B) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, clicks := range []int{0, 1000, 100000} {
+		b.Run(fmt.Sprintf("clicks=%d", clicks), func(b *testing.B) {
+			s := newTestStore(b)
+			link := &model.Link{
+				Code:        "popular",
+				OriginalURL: "https://example.com/popular",
+				CreatedAt:   start,
+				ExpiresAt:   start.Add(365 * 24 * time.Hour),
+			}
+			if err := s.Save(b.Context(), link); err != nil {
+				b.Fatal(err)
+			}
+			events := make([]storage.ClickEvent, clicks)
+			for i := range events {
+				events[i] = storage.ClickEvent{Code: link.Code, Click: model.Click{
+					Timestamp: start.Add(time.Duration(i) * time.Second),
+					IP:        fmt.Sprintf("10.%d.%d.%d", i>>16&255, i>>8&255, i&255),
+				}}
+			}
+			if err := s.RecordClicks(b.Context(), events); err != nil {
+				b.Fatal(err)
+			}
+
+			for b.Loop() {
+				if _, err := s.Resolve(b.Context(), "", link.Code); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
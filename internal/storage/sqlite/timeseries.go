@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"link-shortener/internal/storage"
+)
+
+// ClickTimeseries joins the buckets, passed in as a VALUES table, to the
+// clicks index on (domain, code, is_bot, timestamp). The LEFT JOIN keeps
+// buckets without clicks.
+func (s *Store) ClickTimeseries(ctx context.Context, domain, code string, buckets []storage.TimeBucket, includeBots bool) ([]int, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	var query strings.Builder
+	args := make([]any, 0, 2*len(buckets)+2)
+	query.WriteString(`WITH buckets(idx, bucket_start, bucket_end) AS (VALUES `)
+	for i, bucket := range buckets {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(" + strconv.Itoa(i) + ", ?, ?)")
+		args = append(args, formatTime(bucket.Start), formatTime(bucket.End))
+	}
+	query.WriteString(`)
+		SELECT b.idx, COUNT(c.id)
+		FROM buckets b
+		LEFT JOIN clicks c ON c.domain = ? AND c.code = ?
+			AND c.timestamp >= b.bucket_start AND c.timestamp < b.bucket_end` + botFilter(includeBots) + `
+		GROUP BY b.idx`)
+	args = append(args, domain, code)
+
+	rows, err := s.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, len(buckets))
+	for rows.Next() {
+		var idx, count int
+		if err := rows.Scan(&idx, &count); err != nil {
+			return nil, err
+		}
+		counts[idx] = count
+	}
+	return counts, rows.Err()
+}
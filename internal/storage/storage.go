@@ -1,20 +1,172 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"link-shortener/internal/model"
 )
 
 var (
-	ErrCodeExists = errors.New("short code already exists")
-	ErrNotFound   = errors.New("link not found")
+	ErrCodeExists    = errors.New("short code already exists")
+	ErrNotFound      = errors.New("link not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrKeyNotFound   = errors.New("api key not found")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user already exists")
+
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+	ErrDomainExists      = errors.New("domain already registered")
 )
 
+// Store persists links. Codes are unique per domain; the empty domain is the
+// default one served from BASE_URL. Lookups of missing links return
+// ErrNotFound; any other error means the store could not answer.
+//
+// Unique visitors are counted by model.Click.VisitorKey. Clicks flagged IsBot
+// are kept but never make their visitor unique: UniqueIPs and the default
+// counts cover humans only, and callers that want bots included ask for them
+// explicitly.
 type Store interface {
-	Save(link *model.Link) error
-	Upsert(link *model.Link) error
-	Get(code string) (*model.Link, bool)
-	List() []*model.Link
-	RecordClick(code string, click model.Click) (*model.Link, error)
+	Save(ctx context.Context, link *model.Link) error
+	// ReplaceExpired saves link like Save, but takes over its code when the
+	// link holding it expired before link.CreatedAt. That link is archived
+	// with its clicks as a generation and the new link starts without
+	// analytics. The check and the write are one atomic step; an active
+	// holder yields ErrCodeExists.
+	ReplaceExpired(ctx context.Context, link *model.Link) error
+	Get(ctx context.Context, domain, code string) (*model.Link, error)
+	Resolve(ctx context.Context, domain, code string) (*model.LinkTarget, error)
+	List(ctx context.Context, opts ListOptions) (LinkPage, error)
+	RecordClick(ctx context.Context, domain, code string, click model.Click) error
+	RecordClicks(ctx context.Context, events []ClickEvent) error
+	Update(ctx context.Context, domain, code string, update LinkUpdate) (*model.Link, error)
+	Delete(ctx context.Context, domain, code string) error
+	// ListGenerations returns the archived links that held a code before it
+	// was reclaimed, newest first.
+	ListGenerations(ctx context.Context, domain, code string, includeBots bool) ([]model.GenerationSummary, error)
+	// Generation returns one archived link of a code with its clicks.
+	Generation(ctx context.Context, domain, code string, id int64) (*model.LinkGeneration, error)
+	// ClickTimeseries counts a link's clicks in each bucket, in bucket order.
+	// Buckets without clicks count zero.
+	ClickTimeseries(ctx context.Context, domain, code string, buckets []TimeBucket, includeBots bool) ([]int, error)
+}
+
+// APIKeyStore persists API keys. Only the hash of a key is ever stored.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey, hash string) error
+	APIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int64) error
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	UserByID(ctx context.Context, id int64) (*model.User, error)
+	UserByName(ctx context.Context, name string) (*model.User, error)
+	ListUsers(ctx context.Context) ([]model.User, error)
+	// AssignUnownedLinks gives every link without an owner to the user and
+	// reports how many links were updated.
+	AssignUnownedLinks(ctx context.Context, ownerID int64) (int64, error)
+}
+
+// WorkspaceStore persists workspaces and the domains they own. A domain
+// belongs to at most one workspace.
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, workspace *model.Workspace) error
+	WorkspaceByID(ctx context.Context, id int64) (*model.Workspace, error)
+	WorkspaceByDomain(ctx context.Context, domain string) (*model.Workspace, error)
+	ListWorkspaces(ctx context.Context) ([]model.Workspace, error)
+	AddDomain(ctx context.Context, workspaceID int64, domain string) error
+}
+
+// ClickAnonymizer rewrites stored clicks in place, for databases that
+// collected raw IPs before privacy mode was turned on.
+type ClickAnonymizer interface {
+	// AnonymizeClicks passes every click, archived ones included, whose
+	// visitor is still its raw IP to anonymize and stores the IP and visitor
+	// it leaves behind. Unique visitors are then rebuilt from the clicks. It
+	// reports how many clicks were rewritten.
+	AnonymizeClicks(ctx context.Context, anonymize func(click *model.Click)) (int64, error)
+}
+
+// Migration is the state of one schema migration in a database. AppliedAt is
+// zero while it is pending.
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (m Migration) Pending() bool {
+	return m.AppliedAt.IsZero()
+}
+
+// LinkUpdate describes a partial edit of a link. Nil fields are left unchanged.
+// Setting Code renames the link while keeping its click history.
+type LinkUpdate struct {
+	Code        *string
+	OriginalURL *string
+	ExpiresAt   *time.Time
+}
+
+// ClickEvent is a click destined for the link identified by Domain and Code.
+// Batches passed to RecordClicks silently skip events whose link no longer
+// exists.
+type ClickEvent struct {
+	Domain string
+	Code   string
+	Click  model.Click
+}
+
+// TimeBucket is the half-open range [Start, End) ClickTimeseries counts
+// clicks in.
+type TimeBucket struct {
+	Start time.Time
+	End   time.Time
+}
+
+type LinkStatus string
+
+const (
+	StatusAny     LinkStatus = ""
+	StatusActive  LinkStatus = "active"
+	StatusExpired LinkStatus = "expired"
+)
+
+type SortField string
+
+const (
+	SortCreated        SortField = "created"
+	SortExpires        SortField = "expires"
+	SortClicks         SortField = "clicks"
+	SortUniqueVisitors SortField = "unique"
+)
+
+// ListOptions filters, sorts and paginates List. Zero values disable the
+// corresponding filter; a Limit of zero returns every matching link.
+type ListOptions struct {
+	OwnerID       int64
+	Domain        *string
+	Limit         int
+	Cursor        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	Status        LinkStatus
+	Search        string
+	SortBy        SortField
+	Descending    bool
+	// IncludeBots counts bot clicks, and the IPs they came from as unique
+	// visitors, in the totals.
+	IncludeBots bool
+}
+
+// LinkPage is one page of List results. NextCursor is empty on the last page.
+type LinkPage struct {
+	Links      []model.LinkSummary
+	NextCursor string
 }
@@ -0,0 +1,964 @@
+// Package storagetest is the behaviour every storage.Store must share. Backends
+// call Run from their own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Store { return memory.New() })
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"link-shortener/internal/geo"
+	"link-shortener/internal/model"
+	"link-shortener/internal/storage"
+)
+
+// Factory returns an empty store. It is called once per subtest; register
+// cleanup with t.Cleanup.
+type Factory func(t *testing.T) storage.Store
+
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveExisting", testSaveExisting},
+		{"CodesArePerDomain", testCodesArePerDomain},
+		{"Resolve", testResolve},
+		{"ReplaceExpired", testReplaceExpired},
+		{"Generations", testGenerations},
+		{"RecordClick", testRecordClick},
+		{"ClickTimeseries", testClickTimeseries},
+		{"BotClicks", testBotClicks},
+		{"VisitorKeys", testVisitorKeys},
+		{"RecordClickMissing", testRecordClickMissing},
+		{"RecordClicksSkipsMissing", testRecordClicksSkipsMissing},
+		{"Update", testUpdate},
+		{"UpdateRename", testUpdateRename},
+		{"UpdateRenameConflict", testUpdateRenameConflict},
+		{"UpdateMissing", testUpdateMissing},
+		{"Delete", testDelete},
+		{"ListFilters", testListFilters},
+		{"ListPagination", testListPagination},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"ListTotals", testListTotals},
+		{"ConcurrentClicks", testConcurrentClicks},
+		{"ConcurrentAliasClaims", testConcurrentAliasClaims},
+		{"GeoCache", testGeoCache},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// base is a fixed, microsecond-aligned instant so every backend can store it
+// without rounding.
+var base = time.Date(2030, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+func newLink(domain, code string) *model.Link {
+	return &model.Link{
+		Domain:      domain,
+		Code:        code,
+		OriginalURL: "https://example.com/" + code,
+		CreatedAt:   base,
+		ExpiresAt:   base.Add(24 * time.Hour),
+	}
+}
+
+func mustSave(t *testing.T, s storage.Store, link *model.Link) {
+	t.Helper()
+	if err := s.Save(t.Context(), link); err != nil {
+		t.Fatalf("Save(%s/%s): %v", link.Domain, link.Code, err)
+	}
+}
+
+func mustGet(t *testing.T, s storage.Store, domain, code string) *model.Link {
+	t.Helper()
+	link, err := s.Get(t.Context(), domain, code)
+	if err != nil {
+		t.Fatalf("Get(%s/%s): %v", domain, code, err)
+	}
+	return link
+}
+
+func click(at time.Duration, ip string) model.Click {
+	return model.Click{
+		Timestamp:      base.Add(at),
+		IP:             ip,
+		Country:        "NL",
+		UserAgent:      "test",
+		Referrer:       "example.org/post",
+		Browser:        "Firefox",
+		BrowserVersion: "121",
+		OS:             "Linux",
+		Device:         "desktop",
+		Visitor:        ip,
+	}
+}
+
+func testSaveAndGet(t *testing.T, s storage.Store) {
+	want := newLink("", "abc123")
+	mustSave(t, s, want)
+
+	got := mustGet(t, s, "", "abc123")
+	if got.Code != want.Code || got.Domain != want.Domain || got.OriginalURL != want.OriginalURL {
+		t.Fatalf("Get = %+v, want %+v", got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("times = %v/%v, want %v/%v", got.CreatedAt, got.ExpiresAt, want.CreatedAt, want.ExpiresAt)
+	}
+	if len(got.Clicks) != 0 || len(got.UniqueIPs) != 0 {
+		t.Fatalf("new link has analytics: %d clicks, %d unique", len(got.Clicks), len(got.UniqueIPs))
+	}
+	if _, err := s.Get(t.Context(), "", "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get of a missing code = %v, want ErrNotFound", err)
+	}
+}
+
+func testSaveExisting(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "taken"))
+	other := newLink("", "taken")
+	other.OriginalURL = "https://example.org/"
+	if err := s.Save(t.Context(), other); !errors.Is(err, storage.ErrCodeExists) {
+		t.Fatalf("Save of an existing code = %v, want ErrCodeExists", err)
+	}
+	if got := mustGet(t, s, "", "taken"); got.OriginalURL != "https://example.com/taken" {
+		t.Fatalf("failed Save changed the link to %s", got.OriginalURL)
+	}
+}
+
+func testCodesArePerDomain(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "promo"))
+	other := newLink("go.example.com", "promo")
+	other.OriginalURL = "https://example.org/"
+	mustSave(t, s, other)
+
+	if got := mustGet(t, s, "go.example.com", "promo"); got.OriginalURL != "https://example.org/" {
+		t.Fatalf("domain link = %s", got.OriginalURL)
+	}
+	if got := mustGet(t, s, "", "promo"); got.OriginalURL != "https://example.com/promo" {
+		t.Fatalf("default link = %s", got.OriginalURL)
+	}
+	if err := s.RecordClick(t.Context(), "go.example.com", "promo", click(0, "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mustGet(t, s, "", "promo").Clicks); n != 0 {
+		t.Fatalf("click on another domain counted on the default link: %d", n)
+	}
+}
+
+func testResolve(t *testing.T, s storage.Store) {
+	link := newLink("", "res")
+	mustSave(t, s, link)
+
+	target, err := s.Resolve(t.Context(), "", "res")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if target.Code != "res" || target.OriginalURL != link.OriginalURL || !target.ExpiresAt.Equal(link.ExpiresAt) {
+		t.Fatalf("Resolve = %+v", target)
+	}
+	if _, err := s.Resolve(t.Context(), "", "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Resolve of a missing code = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Resolve(t.Context(), "other.example.com", "res"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Resolve on another domain = %v, want ErrNotFound", err)
+	}
+}
+
+func testReplaceExpired(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "reuse"))
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := s.RecordClick(t.Context(), "", "reuse", click(time.Duration(i)*time.Second, ip)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	early := newLink("", "reuse")
+	early.OriginalURL = "https://example.org/early"
+	early.CreatedAt = base.Add(time.Hour)
+	if err := s.ReplaceExpired(t.Context(), early); !errors.Is(err, storage.ErrCodeExists) {
+		t.Fatalf("ReplaceExpired of an active link = %v, want ErrCodeExists", err)
+	}
+	if got := mustGet(t, s, "", "reuse"); got.OriginalURL != "https://example.com/reuse" || len(got.Clicks) != 2 {
+		t.Fatalf("rejected ReplaceExpired changed the link: %+v", got)
+	}
+
+	replacement := newLink("", "reuse")
+	replacement.OriginalURL = "https://example.org/new"
+	replacement.CreatedAt = base.Add(25 * time.Hour)
+	replacement.ExpiresAt = base.Add(48 * time.Hour)
+	if err := s.ReplaceExpired(t.Context(), replacement); err != nil {
+		t.Fatal(err)
+	}
+	got := mustGet(t, s, "", "reuse")
+	if got.OriginalURL != replacement.OriginalURL || !got.CreatedAt.Equal(replacement.CreatedAt) {
+		t.Fatalf("ReplaceExpired did not replace the link: %+v", got)
+	}
+	if len(got.Clicks) != 0 || len(got.UniqueIPs) != 0 {
+		t.Fatalf("ReplaceExpired kept analytics: %d clicks, %d unique", len(got.Clicks), len(got.UniqueIPs))
+	}
+	if generations, err := s.ListGenerations(t.Context(), "", "reuse", false); err != nil || len(generations) != 1 || generations[0].TotalClicks != 2 {
+		t.Fatalf("ReplaceExpired did not archive the expired link: %+v, %v", generations, err)
+	}
+
+	if err := s.ReplaceExpired(t.Context(), newLink("", "fresh")); err != nil {
+		t.Fatalf("ReplaceExpired of a new code: %v", err)
+	}
+	mustGet(t, s, "", "fresh")
+}
+
+func testGenerations(t *testing.T, s storage.Store) {
+	first := newLink("", "gen")
+	mustSave(t, s, first)
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		if err := s.RecordClick(t.Context(), "", "gen", click(time.Duration(i)*time.Second, ip)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if generations, err := s.ListGenerations(t.Context(), "", "gen", false); err != nil || len(generations) != 0 {
+		t.Fatalf("ListGenerations before any reuse = %v, %v", generations, err)
+	}
+
+	second := newLink("", "gen")
+	second.OriginalURL = "https://example.org/second"
+	second.CreatedAt = base.Add(25 * time.Hour)
+	second.ExpiresAt = base.Add(26 * time.Hour)
+	if err := s.ReplaceExpired(t.Context(), second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordClick(t.Context(), "", "gen", click(25*time.Hour+time.Minute, "10.0.0.9")); err != nil {
+		t.Fatal(err)
+	}
+	third := newLink("", "gen")
+	third.OriginalURL = "https://example.org/third"
+	third.CreatedAt = base.Add(27 * time.Hour)
+	third.ExpiresAt = base.Add(48 * time.Hour)
+	if err := s.ReplaceExpired(t.Context(), third); err != nil {
+		t.Fatal(err)
+	}
+
+	generations, err := s.ListGenerations(t.Context(), "", "gen", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generations) != 2 {
+		t.Fatalf("generations = %d, want 2", len(generations))
+	}
+	newest, oldest := generations[0], generations[1]
+	if newest.OriginalURL != second.OriginalURL || oldest.OriginalURL != first.OriginalURL {
+		t.Fatalf("generations are not newest first: %+v", generations)
+	}
+	if !oldest.ArchivedAt.Equal(second.CreatedAt) || !oldest.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("oldest generation times = created %v, archived %v", oldest.CreatedAt, oldest.ArchivedAt)
+	}
+	if oldest.TotalClicks != 3 || oldest.UniqueVisitors != 2 || newest.TotalClicks != 1 || newest.UniqueVisitors != 1 {
+		t.Fatalf("generation counts = %+v", generations)
+	}
+
+	archived, err := s.Generation(t.Context(), "", "gen", oldest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.OriginalURL != first.OriginalURL || len(archived.Clicks) != 3 || len(archived.UniqueIPs) != 2 {
+		t.Fatalf("Generation = %+v", archived)
+	}
+	if !archived.Clicks[0].Timestamp.Equal(base) || archived.Clicks[2].IP != "10.0.0.1" || archived.Clicks[0].Referrer != "example.org/post" || archived.Clicks[0].Device != "desktop" {
+		t.Fatalf("archived clicks out of order: %+v", archived.Clicks)
+	}
+	if got := mustGet(t, s, "", "gen"); got.OriginalURL != third.OriginalURL || len(got.Clicks) != 0 {
+		t.Fatalf("current link = %+v", got)
+	}
+
+	if _, err := s.Generation(t.Context(), "", "other", oldest.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Generation under another code = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Generation(t.Context(), "", "gen", newest.ID+1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Generation of a missing ID = %v, want ErrNotFound", err)
+	}
+}
+
+func testRecordClick(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "clicky"))
+	clicks := []model.Click{
+		click(2*time.Second, "10.0.0.1"),
+		click(1*time.Second, "10.0.0.2"),
+		click(3*time.Second, "10.0.0.1"),
+		click(4*time.Second, ""),
+	}
+	// Without a visitor the IP identifies the visitor.
+	clicks[1].Visitor = ""
+	for _, c := range clicks {
+		if err := s.RecordClick(t.Context(), "", "clicky", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := mustGet(t, s, "", "clicky")
+	if len(got.Clicks) != 4 {
+		t.Fatalf("clicks = %d, want 4", len(got.Clicks))
+	}
+	if len(got.UniqueIPs) != 2 {
+		t.Fatalf("unique IPs = %d, want 2 (empty IPs are not visitors)", len(got.UniqueIPs))
+	}
+	for i := 1; i < len(got.Clicks); i++ {
+		if got.Clicks[i].Timestamp.Before(got.Clicks[i-1].Timestamp) {
+			t.Fatalf("clicks not ordered by timestamp: %v", got.Clicks)
+		}
+	}
+	first := got.Clicks[0]
+	if first.IP != "10.0.0.2" || first.Country != "NL" || first.UserAgent != "test" || first.Referrer != "example.org/post" || !first.Timestamp.Equal(base.Add(time.Second)) {
+		t.Fatalf("first click = %+v", first)
+	}
+	if first.Visitor != "10.0.0.2" {
+		t.Fatalf("first click visitor = %q, want its IP", first.Visitor)
+	}
+	if first.Browser != "Firefox" || first.BrowserVersion != "121" || first.OS != "Linux" || first.Device != "desktop" {
+		t.Fatalf("first click user agent = %+v", first)
+	}
+}
+
+func testClickTimeseries(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "series"))
+	mustSave(t, s, newLink("", "other"))
+	events := []storage.ClickEvent{
+		{Code: "series", Click: click(0, "10.0.0.1")},
+		{Code: "series", Click: click(59*time.Minute, "10.0.0.1")},
+		{Code: "series", Click: click(time.Hour, "10.0.0.2")},
+		{Code: "series", Click: click(3*time.Hour+time.Second, "10.0.0.3")},
+		{Code: "series", Click: click(-time.Second, "10.0.0.4")},
+		{Code: "other", Click: click(time.Minute, "10.0.0.5")},
+	}
+	if err := s.RecordClicks(t.Context(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	var buckets []storage.TimeBucket
+	for i := 0; i < 4; i++ {
+		start := base.Add(time.Duration(i) * time.Hour)
+		buckets = append(buckets, storage.TimeBucket{Start: start, End: start.Add(time.Hour)})
+	}
+	counts, err := s.ClickTimeseries(t.Context(), "", "series", buckets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 1, 0, 1}; fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Fatalf("ClickTimeseries = %v, want %v", counts, want)
+	}
+
+	counts, err = s.ClickTimeseries(t.Context(), "", "missing", buckets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 0, 0, 0}; fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Fatalf("ClickTimeseries of a missing link = %v, want %v", counts, want)
+	}
+}
+
+func testBotClicks(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "bots"))
+	bot := func(at time.Duration, ip string) model.Click {
+		c := click(at, ip)
+		c.IsBot = true
+		return c
+	}
+	events := []storage.ClickEvent{
+		{Code: "bots", Click: click(0, "10.0.0.1")},
+		{Code: "bots", Click: bot(time.Second, "10.0.0.2")},
+		{Code: "bots", Click: bot(2*time.Second, "10.0.0.1")},
+	}
+	if err := s.RecordClicks(t.Context(), events); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordClick(t.Context(), "", "bots", bot(3*time.Second, "10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := mustGet(t, s, "", "bots")
+	if len(got.Clicks) != 4 || !got.Clicks[1].IsBot || got.Clicks[0].IsBot {
+		t.Fatalf("clicks = %+v", got.Clicks)
+	}
+	if _, ok := got.UniqueIPs["10.0.0.1"]; !ok || len(got.UniqueIPs) != 1 {
+		t.Fatalf("bots became unique visitors: %v", got.UniqueIPs)
+	}
+
+	for _, includeBots := range []bool{false, true} {
+		wantClicks, wantUnique := 1, 1
+		if includeBots {
+			wantClicks, wantUnique = 4, 3
+		}
+		page, err := s.List(t.Context(), storage.ListOptions{IncludeBots: includeBots})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Links) != 1 || page.Links[0].TotalClicks != wantClicks || page.Links[0].UniqueVisitors != wantUnique {
+			t.Fatalf("List(IncludeBots: %v) = %+v", includeBots, page.Links)
+		}
+		buckets := []storage.TimeBucket{{Start: base, End: base.Add(time.Hour)}}
+		counts, err := s.ClickTimeseries(t.Context(), "", "bots", buckets, includeBots)
+		if err != nil || len(counts) != 1 || counts[0] != wantClicks {
+			t.Fatalf("ClickTimeseries(includeBots %v) = %v, %v", includeBots, counts, err)
+		}
+	}
+
+	replacement := newLink("", "bots")
+	replacement.CreatedAt = base.Add(25 * time.Hour)
+	replacement.ExpiresAt = base.Add(48 * time.Hour)
+	if err := s.ReplaceExpired(t.Context(), replacement); err != nil {
+		t.Fatal(err)
+	}
+	for _, includeBots := range []bool{false, true} {
+		wantClicks, wantUnique := 1, 1
+		if includeBots {
+			wantClicks, wantUnique = 4, 3
+		}
+		generations, err := s.ListGenerations(t.Context(), "", "bots", includeBots)
+		if err != nil || len(generations) != 1 || generations[0].TotalClicks != wantClicks || generations[0].UniqueVisitors != wantUnique {
+			t.Fatalf("ListGenerations(includeBots %v) = %+v, %v", includeBots, generations, err)
+		}
+	}
+	generations, _ := s.ListGenerations(t.Context(), "", "bots", false)
+	archived, err := s.Generation(t.Context(), "", "bots", generations[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived.Clicks) != 4 || !archived.Clicks[3].IsBot || len(archived.UniqueIPs) != 1 {
+		t.Fatalf("archived bot clicks = %+v, unique %v", archived.Clicks, archived.UniqueIPs)
+	}
+}
+
+// testVisitorKeys records clicks the way privacy mode does: one truncated IP
+// shared by visitors told apart by their hashes.
+func testVisitorKeys(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "private"))
+	visit := func(at time.Duration, visitor string) model.Click {
+		c := click(at, "10.0.0.0")
+		c.Visitor = visitor
+		return c
+	}
+	if err := s.RecordClick(t.Context(), "", "private", visit(0, "hash-a")); err != nil {
+		t.Fatal(err)
+	}
+	events := []storage.ClickEvent{
+		{Code: "private", Click: visit(time.Second, "hash-b")},
+		{Code: "private", Click: visit(2*time.Second, "hash-a")},
+	}
+	if err := s.RecordClicks(t.Context(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	got := mustGet(t, s, "", "private")
+	if len(got.UniqueIPs) != 2 || got.Clicks[1].Visitor != "hash-b" || got.Clicks[1].IP != "10.0.0.0" {
+		t.Fatalf("visitors = %v, clicks = %+v", got.UniqueIPs, got.Clicks)
+	}
+	for _, includeBots := range []bool{false, true} {
+		page, err := s.List(t.Context(), storage.ListOptions{IncludeBots: includeBots})
+		if err != nil || len(page.Links) != 1 || page.Links[0].UniqueVisitors != 2 {
+			t.Fatalf("List(IncludeBots: %v) = %+v, %v", includeBots, page.Links, err)
+		}
+	}
+
+	replacement := newLink("", "private")
+	replacement.CreatedAt = base.Add(25 * time.Hour)
+	replacement.ExpiresAt = base.Add(48 * time.Hour)
+	if err := s.ReplaceExpired(t.Context(), replacement); err != nil {
+		t.Fatal(err)
+	}
+	generations, err := s.ListGenerations(t.Context(), "", "private", true)
+	if err != nil || len(generations) != 1 || generations[0].UniqueVisitors != 2 {
+		t.Fatalf("ListGenerations = %+v, %v", generations, err)
+	}
+	archived, err := s.Generation(t.Context(), "", "private", generations[0].ID)
+	if err != nil || len(archived.UniqueIPs) != 2 {
+		t.Fatalf("Generation visitors = %+v, %v", archived, err)
+	}
+}
+
+func testRecordClickMissing(t *testing.T, s storage.Store) {
+	if err := s.RecordClick(t.Context(), "", "ghost", click(0, "10.0.0.1")); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("RecordClick on a missing code = %v, want ErrNotFound", err)
+	}
+}
+
+func testRecordClicksSkipsMissing(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "batch"))
+	events := []storage.ClickEvent{
+		{Code: "batch", Click: click(0, "10.0.0.1")},
+		{Code: "ghost", Click: click(0, "10.0.0.1")},
+		{Domain: "other.example.com", Code: "batch", Click: click(0, "10.0.0.3")},
+		{Code: "batch", Click: click(time.Second, "10.0.0.2")},
+	}
+	if err := s.RecordClicks(t.Context(), events); err != nil {
+		t.Fatalf("RecordClicks: %v", err)
+	}
+	if err := s.RecordClicks(t.Context(), nil); err != nil {
+		t.Fatalf("RecordClicks(nil): %v", err)
+	}
+	got := mustGet(t, s, "", "batch")
+	if len(got.Clicks) != 2 || len(got.UniqueIPs) != 2 {
+		t.Fatalf("got %d clicks, %d unique; want 2, 2", len(got.Clicks), len(got.UniqueIPs))
+	}
+	if got.Clicks[0] != events[0].Click {
+		t.Fatalf("batched click = %+v, want %+v", got.Clicks[0], events[0].Click)
+	}
+}
+
+func testUpdate(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "edit"))
+	url := "https://example.org/edited"
+	expires := base.Add(48 * time.Hour)
+
+	got, err := s.Update(t.Context(), "", "edit", storage.LinkUpdate{OriginalURL: &url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OriginalURL != url || !got.ExpiresAt.Equal(base.Add(24*time.Hour)) {
+		t.Fatalf("after URL update: %+v", got)
+	}
+	got, err = s.Update(t.Context(), "", "edit", storage.LinkUpdate{ExpiresAt: &expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OriginalURL != url || !got.ExpiresAt.Equal(expires) {
+		t.Fatalf("after expiry update: %+v", got)
+	}
+	if target, err := s.Resolve(t.Context(), "", "edit"); err != nil || target.OriginalURL != url {
+		t.Fatalf("Resolve after update = %+v", target)
+	}
+}
+
+func testUpdateRename(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "before"))
+	if err := s.RecordClick(t.Context(), "", "before", click(0, "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	code := "after"
+	got, err := s.Update(t.Context(), "", "before", storage.LinkUpdate{Code: &code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != "after" || len(got.Clicks) != 1 || len(got.UniqueIPs) != 1 {
+		t.Fatalf("renamed link = %+v", got)
+	}
+	if _, err := s.Get(t.Context(), "", "before"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get of the old code after rename = %v, want ErrNotFound", err)
+	}
+	if err := s.RecordClick(t.Context(), "", "before", click(0, "10.0.0.1")); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("RecordClick on the old code = %v, want ErrNotFound", err)
+	}
+	same := "after"
+	if _, err := s.Update(t.Context(), "", "after", storage.LinkUpdate{Code: &same}); err != nil {
+		t.Fatalf("renaming to the same code: %v", err)
+	}
+}
+
+func testUpdateRenameConflict(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "one"))
+	mustSave(t, s, newLink("", "two"))
+	code, url := "two", "https://example.org/changed"
+	if _, err := s.Update(t.Context(), "", "one", storage.LinkUpdate{Code: &code, OriginalURL: &url}); !errors.Is(err, storage.ErrCodeExists) {
+		t.Fatalf("rename onto an existing code = %v, want ErrCodeExists", err)
+	}
+	if got := mustGet(t, s, "", "one"); got.OriginalURL != "https://example.com/one" {
+		t.Fatalf("failed rename still changed the URL to %s", got.OriginalURL)
+	}
+	mustGet(t, s, "", "two")
+}
+
+func testUpdateMissing(t *testing.T, s storage.Store) {
+	url := "https://example.org/"
+	if _, err := s.Update(t.Context(), "", "ghost", storage.LinkUpdate{OriginalURL: &url}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Update of a missing code = %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "gone"))
+	if err := s.RecordClick(t.Context(), "", "gone", click(0, "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(t.Context(), "", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(t.Context(), "", "gone"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(t.Context(), "", "gone"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+
+	// A new link with the same code starts without the old analytics.
+	mustSave(t, s, newLink("", "gone"))
+	if got := mustGet(t, s, "", "gone"); len(got.Clicks) != 0 || len(got.UniqueIPs) != 0 {
+		t.Fatalf("recreated link inherited analytics: %+v", got)
+	}
+}
+
+func codes(page storage.LinkPage) []string {
+	var out []string
+	for _, link := range page.Links {
+		out = append(out, link.Domain+"/"+link.Code)
+	}
+	return out
+}
+
+func sameCodes(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testListFilters(t *testing.T, s storage.Store) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	links := []*model.Link{
+		{Code: "alpha", OriginalURL: "https://example.com/Docs", CreatedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{Code: "beta", OriginalURL: "https://example.org/", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Minute)},
+		{Domain: "go.example.com", Code: "gamma", OriginalURL: "https://example.net/docs", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(2 * time.Hour)},
+	}
+	for _, link := range links {
+		mustSave(t, s, link)
+	}
+	other := "go.example.com"
+	empty := ""
+
+	tests := []struct {
+		name string
+		opts storage.ListOptions
+		want []string
+	}{
+		{"all", storage.ListOptions{}, []string{"/alpha", "/beta", "go.example.com/gamma"}},
+		{"descending", storage.ListOptions{Descending: true}, []string{"go.example.com/gamma", "/beta", "/alpha"}},
+		{"active", storage.ListOptions{Status: storage.StatusActive}, []string{"/alpha", "go.example.com/gamma"}},
+		{"expired", storage.ListOptions{Status: storage.StatusExpired}, []string{"/beta"}},
+		{"search is case-insensitive", storage.ListOptions{Search: "DOCS"}, []string{"/alpha", "go.example.com/gamma"}},
+		{"search code", storage.ListOptions{Search: "bet"}, []string{"/beta"}},
+		{"search escapes wildcards", storage.ListOptions{Search: "%"}, nil},
+		{"created range", storage.ListOptions{CreatedAfter: now.Add(-2 * time.Hour), CreatedBefore: now.Add(-time.Hour)}, []string{"/beta"}},
+		{"expires range", storage.ListOptions{ExpiresAfter: now, ExpiresBefore: now.Add(90 * time.Minute)}, []string{"/alpha"}},
+		{"domain", storage.ListOptions{Domain: &other}, []string{"go.example.com/gamma"}},
+		{"default domain", storage.ListOptions{Domain: &empty}, []string{"/alpha", "/beta"}},
+		{"sort by expiry", storage.ListOptions{SortBy: storage.SortExpires}, []string{"/beta", "/alpha", "go.example.com/gamma"}},
+	}
+	for _, tt := range tests {
+		page, err := s.List(t.Context(), tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := codes(page); !sameCodes(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if page.NextCursor != "" {
+			t.Errorf("%s: unexpected cursor on an unlimited listing", tt.name)
+		}
+	}
+
+	if _, err := s.List(t.Context(), storage.ListOptions{SortBy: "nope"}); err == nil {
+		t.Error("unknown sort field accepted")
+	}
+	if _, err := s.List(t.Context(), storage.ListOptions{Status: "nope"}); err == nil {
+		t.Error("unknown status accepted")
+	}
+}
+
+func testListPagination(t *testing.T, s storage.Store) {
+	// Equal click counts and codes repeated across domains exercise the
+	// tie-breakers that keep pages from overlapping.
+	var all []string
+	for i := 0; i < 7; i++ {
+		for _, domain := range []string{"", "go.example.com"} {
+			code := fmt.Sprintf("p%d", i)
+			link := newLink(domain, code)
+			link.CreatedAt = base.Add(time.Duration(i%3) * time.Minute)
+			mustSave(t, s, link)
+			for n := 0; n < i%2; n++ {
+				if err := s.RecordClick(t.Context(), domain, code, click(0, fmt.Sprintf("10.0.0.%d", n))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			all = append(all, domain+"/"+code)
+		}
+	}
+
+	sorts := []storage.SortField{storage.SortCreated, storage.SortExpires, storage.SortClicks, storage.SortUniqueVisitors}
+	for _, sortBy := range sorts {
+		for _, desc := range []bool{false, true} {
+			full, err := s.List(t.Context(), storage.ListOptions{SortBy: sortBy, Descending: desc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := codes(full)
+			if len(want) != len(all) {
+				t.Fatalf("%s desc=%v: unlimited listing returned %d links, want %d", sortBy, desc, len(want), len(all))
+			}
+
+			var got []string
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(all) {
+					t.Fatalf("%s desc=%v: pagination does not terminate", sortBy, desc)
+				}
+				page, err := s.List(t.Context(), storage.ListOptions{SortBy: sortBy, Descending: desc, Limit: 3, Cursor: cursor})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Links) > 3 {
+					t.Fatalf("%s desc=%v: page of %d exceeds limit", sortBy, desc, len(page.Links))
+				}
+				got = append(got, codes(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+			if !sameCodes(got, want) {
+				t.Errorf("%s desc=%v: paginated %v, want %v", sortBy, desc, got, want)
+			}
+		}
+	}
+}
+
+func testListInvalidCursor(t *testing.T, s storage.Store) {
+	for i := 0; i < 3; i++ {
+		mustSave(t, s, newLink("", fmt.Sprintf("c%d", i)))
+	}
+	if _, err := s.List(t.Context(), storage.ListOptions{Limit: 1, Cursor: "not a cursor"}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Fatalf("garbage cursor = %v, want ErrInvalidCursor", err)
+	}
+	page, err := s.List(t.Context(), storage.ListOptions{Limit: 1, SortBy: storage.SortCreated})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v, cursor %q", err, page.NextCursor)
+	}
+	if _, err := s.List(t.Context(), storage.ListOptions{Limit: 1, SortBy: storage.SortClicks, Cursor: page.NextCursor}); !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Fatalf("cursor reused with another sort = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// testListTotals follows a link's listed totals through the writes that
+// change them, for backends that keep the totals rather than count them.
+func testListTotals(t *testing.T, s storage.Store) {
+	ctx := t.Context()
+	totals := func(code string) [2]int {
+		t.Helper()
+		page, err := s.List(ctx, storage.ListOptions{Search: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, link := range page.Links {
+			if link.Code == code {
+				return [2]int{link.TotalClicks, link.UniqueVisitors}
+			}
+		}
+		t.Fatalf("%s not listed", code)
+		return [2]int{}
+	}
+	want := func(code string, clicks, visitors int) {
+		t.Helper()
+		if got := totals(code); got != [2]int{clicks, visitors} {
+			t.Fatalf("%s totals = %v, want [%d %d]", code, got, clicks, visitors)
+		}
+	}
+
+	mustSave(t, s, newLink("", "tally"))
+	bot := click(3*time.Second, "10.0.0.9")
+	bot.IsBot = true
+	if err := s.RecordClick(ctx, "", "tally", click(0, "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordClicks(ctx, []storage.ClickEvent{
+		{Code: "tally", Click: click(time.Second, "10.0.0.1")},
+		{Code: "tally", Click: click(2*time.Second, "10.0.0.2")},
+		{Code: "tally", Click: bot},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want("tally", 3, 2)
+
+	code := "counted"
+	if _, err := s.Update(ctx, "", "tally", storage.LinkUpdate{Code: &code}); err != nil {
+		t.Fatal(err)
+	}
+	want("counted", 3, 2)
+
+	replacement := newLink("", "counted")
+	replacement.CreatedAt = base.Add(25 * time.Hour)
+	replacement.ExpiresAt = base.Add(48 * time.Hour)
+	if err := s.ReplaceExpired(ctx, replacement); err != nil {
+		t.Fatal(err)
+	}
+	want("counted", 0, 0)
+	if err := s.RecordClick(ctx, "", "counted", click(25*time.Hour, "10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	want("counted", 1, 1)
+
+	if err := s.Delete(ctx, "", "counted"); err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, newLink("", "counted"))
+	want("counted", 0, 0)
+}
+
+func testConcurrentClicks(t *testing.T, s storage.Store) {
+	mustSave(t, s, newLink("", "busy"))
+	const workers, perWorker = 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				c := click(time.Duration(i)*time.Millisecond, fmt.Sprintf("10.0.%d.%d", w, i%5))
+				if i%2 == 0 {
+					errs <- s.RecordClick(t.Context(), "", "busy", c)
+				} else {
+					errs <- s.RecordClicks(t.Context(), []storage.ClickEvent{{Code: "busy", Click: c}})
+				}
+				if _, err := s.Get(t.Context(), "", "busy"); err != nil {
+					errs <- fmt.Errorf("Get during concurrent writes: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := mustGet(t, s, "", "busy")
+	if len(got.Clicks) != workers*perWorker {
+		t.Fatalf("clicks = %d, want %d", len(got.Clicks), workers*perWorker)
+	}
+	if len(got.UniqueIPs) != workers*5 {
+		t.Fatalf("unique IPs = %d, want %d", len(got.UniqueIPs), workers*5)
+	}
+}
+
+// testConcurrentAliasClaims races many writers for one alias, first while it
+// is free and then once it has expired. Exactly one writer may win each round
+// and everyone else must see ErrCodeExists.
+func testConcurrentAliasClaims(t *testing.T, s storage.Store) {
+	const writers = 32
+
+	claim := func(createdAt time.Time) {
+		t.Helper()
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		winners := make(chan string, writers)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				link := newLink("", "hot")
+				link.OriginalURL = fmt.Sprintf("https://example.com/%d/%d", createdAt.Unix(), w)
+				link.CreatedAt = createdAt
+				link.ExpiresAt = createdAt.Add(time.Hour)
+				switch err := s.ReplaceExpired(t.Context(), link); {
+				case err == nil:
+					winners <- link.OriginalURL
+				case !errors.Is(err, storage.ErrCodeExists):
+					errs <- err
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		close(winners)
+		for err := range errs {
+			t.Fatal(err)
+		}
+
+		var won []string
+		for url := range winners {
+			won = append(won, url)
+		}
+		if len(won) != 1 {
+			t.Fatalf("%d writers claimed the alias, want 1", len(won))
+		}
+		if got := mustGet(t, s, "", "hot"); got.OriginalURL != won[0] {
+			t.Fatalf("stored %s, but the winner wrote %s", got.OriginalURL, won[0])
+		}
+	}
+
+	claim(base)
+	claim(base.Add(2 * time.Hour))
+}
+
+// geoCache is implemented by the SQL stores, which persist geo lookups.
+type geoCache interface {
+	geo.Persister
+	PruneLocations(ctx context.Context, now time.Time) (int64, error)
+}
+
+func testGeoCache(t *testing.T, s storage.Store) {
+	c, ok := s.(geoCache)
+	if !ok {
+		t.Skip("store does not persist geo lookups")
+	}
+	ctx := t.Context()
+	now := time.Now()
+	loc := geo.Location{Country: "DE", Region: "Berlin", City: "Berlin", ASN: 3320, ASOrg: "Deutsche Telekom AG"}
+
+	if err := c.SaveLocation(ctx, "203.0.113.0/24", loc, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := c.LoadLocation(ctx, "203.0.113.0/24", now); err != nil || !ok || got != loc {
+		t.Fatalf("LoadLocation = %+v, %v, %v; want %+v", got, ok, err, loc)
+	}
+	if _, ok, err := c.LoadLocation(ctx, "203.0.113.0/24", now.Add(2*time.Hour)); err != nil || ok {
+		t.Fatalf("expired entry loaded: %v, %v", ok, err)
+	}
+	if _, ok, err := c.LoadLocation(ctx, "198.51.100.0/24", now); err != nil || ok {
+		t.Fatalf("unknown prefix loaded: %v, %v", ok, err)
+	}
+	moved := geo.Location{Country: "FR"}
+	if err := c.SaveLocation(ctx, "203.0.113.0/24", moved, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := c.LoadLocation(ctx, "203.0.113.0/24", now); err != nil || got != moved {
+		t.Fatalf("after overwrite LoadLocation = %+v, %v; want %+v", got, err, moved)
+	}
+
+	if err := c.SaveLocation(ctx, "192.0.2.0/24", loc, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.PruneLocations(ctx, now); err != nil || n != 1 {
+		t.Fatalf("PruneLocations = %d, %v; want 1 expired row", n, err)
+	}
+	if _, ok, err := c.LoadLocation(ctx, "203.0.113.0/24", now); err != nil || !ok {
+		t.Fatalf("live entry pruned: %v, %v", ok, err)
+	}
+
+	// Saving alone must sweep expired rows out eventually; 1024 saves is more
+	// than any backend waits between sweeps.
+	if err := c.SaveLocation(ctx, "192.0.2.0/24", loc, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1024; i++ {
+		if err := c.SaveLocation(ctx, fmt.Sprintf("10.0.%d.0/24", i%256), loc, now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := c.PruneLocations(ctx, now); err != nil || n != 0 {
+		t.Fatalf("%d expired rows left after 1024 saves (err %v)", n, err)
+	}
+}
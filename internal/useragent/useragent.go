@@ -0,0 +1,157 @@
+// Package useragent classifies User-Agent headers into a browser family and
+// major version, an operating system and a device class. It recognises the
+// common browsers and crawlers by their product tokens; anything else is
+// reported as Other.
+package useragent
+
+import "strings"
+
+const Other = "Other"
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	// DeviceUnknown is reported for an empty User-Agent.
+	DeviceUnknown = "unknown"
+)
+
+type Agent struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	Device         string
+}
+
+// browser is a product token whose version follows it directly, as in
+// "Firefox/121.0". Order matters: Chromium-based browsers also send
+// "Chrome/" and almost everything sends "Safari/".
+type browser struct {
+	name  string
+	token string
+}
+
+var bots = []browser{
+	{"Googlebot", "googlebot/"},
+	{"Bingbot", "bingbot/"},
+	{"DuckDuckBot", "duckduckbot/"},
+	{"YandexBot", "yandexbot/"},
+	{"Baiduspider", "baiduspider/"},
+	{"Applebot", "applebot/"},
+	{"Facebook", "facebookexternalhit/"},
+	{"Twitterbot", "twitterbot/"},
+	{"LinkedInBot", "linkedinbot/"},
+	{"Slackbot", "slackbot"},
+	{"Discordbot", "discordbot/"},
+	{"TelegramBot", "telegrambot"},
+	{"WhatsApp", "whatsapp/"},
+	{"curl", "curl/"},
+	{"Wget", "wget/"},
+	{"Python Requests", "python-requests/"},
+	{"Go HTTP Client", "go-http-client/"},
+}
+
+// botMarkers flag crawlers that are not listed in bots by name.
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "headlesschrome", "preview"}
+
+var browsers = []browser{
+	{"Edge", "edg/"},
+	{"Edge", "edga/"},
+	{"Edge", "edgios/"},
+	{"Edge", "edge/"},
+	{"Opera", "opr/"},
+	{"Opera", "opera/"},
+	{"Samsung Internet", "samsungbrowser/"},
+	{"Yandex Browser", "yabrowser/"},
+	{"Firefox", "fxios/"},
+	{"Firefox", "firefox/"},
+	{"Chrome", "crios/"},
+	{"Chrome", "chrome/"},
+	{"Safari", "version/"},
+}
+
+func Parse(ua string) Agent {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return Agent{Browser: Other, OS: Other, Device: DeviceUnknown}
+	}
+	lower := strings.ToLower(ua)
+	agent := Agent{Browser: Other, OS: parseOS(lower)}
+
+	if name, version, ok := match(lower, bots); ok {
+		agent.Browser, agent.BrowserVersion, agent.Device = name, version, DeviceBot
+		return agent
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(lower, marker) {
+			agent.Device = DeviceBot
+			return agent
+		}
+	}
+
+	if name, version, ok := match(lower, browsers); ok {
+		// "Version/" only names Safari when the page is not an Android
+		// WebView, which sends the same token.
+		if name != "Safari" || strings.Contains(lower, "safari/") && agent.OS != "Android" {
+			agent.Browser, agent.BrowserVersion = name, version
+		}
+	} else if strings.Contains(lower, "trident/") || strings.Contains(lower, "msie ") {
+		agent.Browser = "Internet Explorer"
+		if _, version, ok := match(lower, []browser{{"", "msie "}, {"", "rv:"}}); ok {
+			agent.BrowserVersion = version
+		}
+	}
+	agent.Device = parseDevice(lower, agent.OS)
+	return agent
+}
+
+// match returns the first browser whose token occurs in ua with the major
+// version that follows it.
+func match(ua string, list []browser) (name, version string, ok bool) {
+	for _, b := range list {
+		i := strings.Index(ua, b.token)
+		if i < 0 {
+			continue
+		}
+		rest := ua[i+len(b.token):]
+		end := 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		return b.name, rest[:end], true
+	}
+	return "", "", false
+}
+
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "windows phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return "iOS"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "cros "):
+		return "ChromeOS"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return "Linux"
+	}
+	return Other
+}
+
+// parseDevice tells tablets from phones by the platform's conventions:
+// Android tablets omit "Mobile", and iPads say so.
+func parseDevice(ua, os string) string {
+	switch {
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"), os == "Android" && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"), os == "Windows Phone":
+		return DeviceMobile
+	}
+	return DeviceDesktop
+}
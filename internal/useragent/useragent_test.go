@@ -0,0 +1,183 @@
+package useragent
+
+import "testing"
+
+func agent(browser, version, os, device string) Agent {
+	return Agent{Browser: browser, BrowserVersion: version, OS: os, Device: device}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Agent
+	}{
+		{
+			"ChromeWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			agent("Chrome", "120", "Windows", DeviceDesktop),
+		},
+		{
+			"ChromeMac",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			agent("Chrome", "119", "macOS", DeviceDesktop),
+		},
+		{
+			"ChromeLinux",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			agent("Chrome", "120", "Linux", DeviceDesktop),
+		},
+		{
+			"ChromeOS",
+			"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36",
+			agent("Chrome", "118", "ChromeOS", DeviceDesktop),
+		},
+		{
+			"ChromeAndroidPhone",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			agent("Chrome", "120", "Android", DeviceMobile),
+		},
+		{
+			"ChromeAndroidTablet",
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			agent("Chrome", "120", "Android", DeviceTablet),
+		},
+		{
+			"ChromeIOS",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			agent("Chrome", "120", "iOS", DeviceMobile),
+		},
+		{
+			"SafariMac",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			agent("Safari", "17", "macOS", DeviceDesktop),
+		},
+		{
+			"SafariIPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			agent("Safari", "17", "iOS", DeviceMobile),
+		},
+		{
+			"SafariIPad",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			agent("Safari", "16", "iOS", DeviceTablet),
+		},
+		{
+			"FirefoxWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			agent("Firefox", "121", "Windows", DeviceDesktop),
+		},
+		{
+			"FirefoxLinux",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			agent("Firefox", "120", "Linux", DeviceDesktop),
+		},
+		{
+			"FirefoxAndroid",
+			"Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			agent("Firefox", "121", "Android", DeviceMobile),
+		},
+		{
+			"FirefoxIOS",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			agent("Firefox", "121", "iOS", DeviceMobile),
+		},
+		{
+			"EdgeWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			agent("Edge", "120", "Windows", DeviceDesktop),
+		},
+		{
+			"EdgeLegacy",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19041",
+			agent("Edge", "18", "Windows", DeviceDesktop),
+		},
+		{
+			"OperaWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			agent("Opera", "105", "Windows", DeviceDesktop),
+		},
+		{
+			"SamsungInternet",
+			"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			agent("Samsung Internet", "23", "Android", DeviceMobile),
+		},
+		{
+			"AndroidWebView",
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.0.0 Mobile Safari/537.36",
+			agent("Chrome", "120", "Android", DeviceMobile),
+		},
+		{
+			"InternetExplorer11",
+			"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			agent("Internet Explorer", "11", "Windows", DeviceDesktop),
+		},
+		{
+			"InternetExplorer9",
+			"Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Trident/5.0)",
+			agent("Internet Explorer", "9", "Windows", DeviceDesktop),
+		},
+		{
+			"Googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			agent("Googlebot", "2", Other, DeviceBot),
+		},
+		{
+			"GooglebotSmartphone",
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.71 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			agent("Googlebot", "2", "Android", DeviceBot),
+		},
+		{
+			"Bingbot",
+			"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			agent("Bingbot", "2", Other, DeviceBot),
+		},
+		{
+			"FacebookPreview",
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			agent("Facebook", "1", Other, DeviceBot),
+		},
+		{
+			"Slackbot",
+			"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			agent("Slackbot", "", Other, DeviceBot),
+		},
+		{
+			"Curl",
+			"curl/8.4.0",
+			agent("curl", "8", Other, DeviceBot),
+		},
+		{
+			"GoClient",
+			"Go-http-client/1.1",
+			agent("Go HTTP Client", "1", Other, DeviceBot),
+		},
+		{
+			"UnnamedCrawler",
+			"Mozilla/5.0 (compatible; ExampleCrawler/1.0; +https://example.com/crawler)",
+			agent(Other, "", Other, DeviceBot),
+		},
+		{
+			"HeadlessChrome",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36",
+			agent(Other, "", "Linux", DeviceBot),
+		},
+		{
+			"Empty",
+			"",
+			agent(Other, "", Other, DeviceUnknown),
+		},
+		{
+			"Unrecognised",
+			"SomeApp/3.2 (custom client)",
+			agent(Other, "", Other, DeviceDesktop),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.ua); got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.ua, got, tt.want)
+			}
+		})
+	}
+}